@@ -34,6 +34,15 @@ import (
 type benchmarkMethod struct {
 	serializer encoding.Serializer
 	req        *transport.Request
+
+	// scenario is set when benchmarking a sequence of dependent calls.
+	// The serializer and req are then the scenario's first step, which is
+	// used for the initial request and to warm up transports.
+	scenario *scenarioMethod
+
+	// serverTime is set when the network overhead should be reported using
+	// a server time field in the response.
+	serverTime *serverTimeField
 }
 
 // WarmTransport warms up a transport and returns it. The transport is warmed
@@ -74,24 +83,28 @@ func hostPortBalancer(hostPorts []string) func(i int) string {
 	}
 }
 
-// WarmTransports returns n transports that have been warmed up.
+// WarmTransports returns n transports that have been warmed up, along with
+// the peer that each transport is connected to.
 // No requests may fail during the warmup period.
-func (m benchmarkMethod) WarmTransports(n int, tOpts TransportOptions, warmupRequests int) ([]transport.Transport, error) {
+func (m benchmarkMethod) WarmTransports(n int, tOpts TransportOptions, warmupRequests int) ([]transport.Transport, []string, error) {
 	tOpts, err := loadTransportHostPorts(tOpts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	hostPortFor := hostPortBalancer(tOpts.HostPorts)
 	transports := make([]transport.Transport, n)
+	peers := make([]string, n)
 	errs := make([]error, n)
 
 	var wg sync.WaitGroup
 	for i := range transports {
+		peers[i] = hostPortFor(i)
+
 		wg.Add(1)
 		go func(i int, tOpts TransportOptions) {
 			defer wg.Done()
-			tOpts.HostPorts = []string{hostPortFor(i)}
+			tOpts.HostPorts = []string{peers[i]}
 			transports[i], errs[i] = m.WarmTransport(tOpts, warmupRequests)
 		}(i, tOpts)
 	}
@@ -101,9 +114,9 @@ func (m benchmarkMethod) WarmTransports(n int, tOpts TransportOptions, warmupReq
 	// If we hit any errors, return the first one.
 	for _, err := range errs {
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	return transports, nil
+	return transports, peers, nil
 }
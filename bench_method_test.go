@@ -23,6 +23,7 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -51,7 +52,7 @@ func benchmarkMethodForTest(t *testing.T, methodString string, p transport.Proto
 	require.NoError(t, err, "Failed to serialize Thrift body")
 
 	req.Timeout = time.Second
-	return benchmarkMethod{serializer, req}
+	return benchmarkMethod{serializer: serializer, req: req}
 }
 
 func TestBenchmarkMethodWarmTransport(t *testing.T) {
@@ -225,12 +226,17 @@ func TestBenchmarkMethodWarmTransportsSuccess(t *testing.T) {
 		ServiceName: "foo",
 		HostPorts:   serverHPs,
 	}
-	transports, err := m.WarmTransports(numServers, tOpts, 1 /* warmupRequests */)
+	transports, peers, err := m.WarmTransports(numServers, tOpts, 1 /* warmupRequests */)
 	assert.NoError(t, err, "WarmTransports should not fail")
 	assert.Equal(t, numServers, len(transports), "Got unexpected number of transports")
 	for i, transport := range transports {
 		assert.NotNil(t, transport, "transports[%v] should not be nil", i)
 	}
+	sortedPeers := append([]string(nil), peers...)
+	sortedHPs := append([]string(nil), serverHPs...)
+	sort.Strings(sortedPeers)
+	sort.Strings(sortedHPs)
+	assert.Equal(t, sortedHPs, sortedPeers, "Each server should be used by one transport")
 
 	// Verify that each server has received one call.
 	for i, counter := range counters {
@@ -284,7 +290,7 @@ func TestBenchmarkMethodWarmTransportsError(t *testing.T) {
 			ServiceName: "foo",
 			HostPorts:   []string{s.hostPort()},
 		}
-		_, err := m.WarmTransports(10, tOpts, tt.warmup)
+		_, _, err := m.WarmTransports(10, tOpts, tt.warmup)
 		if tt.wantErr {
 			assert.Error(t, err, "%v: WarmTransports should fail", msg)
 		} else {
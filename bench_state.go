@@ -36,6 +36,12 @@ type benchmarkState struct {
 	totalSuccess  int
 	totalRequests int
 	latencies     []time.Duration
+
+	// overheads is the latency minus the server-reported time per request.
+	overheads []time.Duration
+
+	// steps contains the state for each step when running a scenario.
+	steps []*benchmarkState
 }
 
 func newBenchmarkState(statter statsd.Client) *benchmarkState {
@@ -66,6 +72,10 @@ func (s *benchmarkState) merge(other *benchmarkState) {
 		s.errors[k] += v
 	}
 	s.latencies = append(s.latencies, other.latencies...)
+	s.overheads = append(s.overheads, other.overheads...)
+	for i, step := range other.steps {
+		s.steps[i].merge(step)
+	}
 	s.totalErrors += other.totalErrors
 	s.totalSuccess += other.totalSuccess
 	s.totalRequests += other.totalRequests
@@ -79,12 +89,35 @@ func (s *benchmarkState) recordLatency(d time.Duration) {
 	s.statter.Timing("latency", d)
 }
 
+// recordOverhead records the difference between the measured latency and
+// the time the server reported spending on the request.
+func (s *benchmarkState) recordOverhead(d time.Duration) {
+	s.overheads = append(s.overheads, d)
+	s.statter.Timing("overhead", d)
+}
+
+func (s *benchmarkState) printOverheads(out output) {
+	if len(s.overheads) == 0 {
+		return
+	}
+
+	sort.Sort(byDuration(s.overheads))
+	out.Printf("Network overhead (latency - server time):\n")
+	overheads := &benchmarkState{latencies: s.overheads}
+	overheads.printQuantiles(out, "  ")
+}
+
 func (s *benchmarkState) printLatencies(out output) {
 	// TODO JSON output?
 	sort.Sort(byDuration(s.latencies))
 	out.Printf("Latencies:\n")
+	s.printQuantiles(out, "  ")
+}
+
+// printQuantiles prints the latency quantiles, which must be sorted.
+func (s *benchmarkState) printQuantiles(out output, indent string) {
 	for _, quantile := range []float64{0.5, 0.9, 0.95, 0.99, 0.999, 0.9995, 1.0} {
-		out.Printf("  %.4f: %v\n", quantile, s.getQuantile(quantile))
+		out.Printf("%v%.4f: %v\n", indent, quantile, s.getQuantile(quantile))
 	}
 }
 
@@ -0,0 +1,191 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yarpc/yab/sorted"
+)
+
+const (
+	// defaultTableWidth is used when the output is not a terminal, or
+	// the terminal width is unknown.
+	defaultTableWidth = 80
+
+	// minMetricWidth is the narrowest the metric column will be truncated to.
+	minMetricWidth = 8
+)
+
+// The list of supported benchmark summary formats.
+const (
+	formatPlain = "plain"
+	formatTable = "table"
+)
+
+type summaryRow struct {
+	metric string
+	value  string
+	unit   string
+}
+
+// summaryRows returns the rows of the benchmark summary for the given state.
+func (s *benchmarkState) summaryRows(total time.Duration) []summaryRow {
+	sort.Sort(byDuration(s.latencies))
+
+	rows := []summaryRow{
+		{"Elapsed time", fmt.Sprintf("%.3f", total.Seconds()), "s"},
+		{"Total requests", strconv.Itoa(s.totalRequests), "requests"},
+		{"RPS", fmt.Sprintf("%.2f", float64(s.totalRequests)/total.Seconds()), "requests/s"},
+	}
+	rows = append(rows, s.latencyRows("Latency")...)
+	if len(s.overheads) > 0 {
+		sort.Sort(byDuration(s.overheads))
+		overheads := &benchmarkState{latencies: s.overheads}
+		rows = append(rows, overheads.latencyRows("Overhead")...)
+	}
+
+	if s.totalErrors > 0 {
+		rows = append(rows,
+			summaryRow{"Total errors", strconv.Itoa(s.totalErrors), "errors"},
+			summaryRow{"Error rate", fmt.Sprintf("%.4f", 100*float32(s.totalErrors)/float32(s.totalRequests)), "%"},
+		)
+		for _, k := range sorted.MapKeys(s.errors) {
+			rows = append(rows, summaryRow{"Error: " + k, strconv.Itoa(s.errors[k]), "errors"})
+		}
+	}
+
+	return rows
+}
+
+// latencyRows returns a row for each latency quantile, which must be sorted.
+func (s *benchmarkState) latencyRows(prefix string) []summaryRow {
+	var rows []summaryRow
+	for _, quantile := range []float64{0.5, 0.9, 0.95, 0.99, 0.999, 0.9995, 1.0} {
+		rows = append(rows, summaryRow{
+			metric: fmt.Sprintf("%v p%v", prefix, strconv.FormatFloat(quantile*100, 'f', -1, 64)),
+			value:  fmt.Sprintf("%.3f", float64(s.getQuantile(quantile))/float64(time.Millisecond)),
+			unit:   "ms",
+		})
+	}
+	return rows
+}
+
+// printTable prints the rows as a table with aligned columns that fits
+// within width, truncating metric names if required. Widths are measured in
+// runes, which is also how fmt pads, so error messages with non-ASCII text
+// stay aligned.
+func printTable(out output, rows []summaryRow, width int) {
+	header := summaryRow{"Metric", "Value", "Unit"}
+	metricW, valueW, unitW := runeWidth(header.metric), runeWidth(header.value), runeWidth(header.unit)
+	for _, r := range rows {
+		metricW = maxInt(metricW, runeWidth(r.metric))
+		valueW = maxInt(valueW, runeWidth(r.value))
+		unitW = maxInt(unitW, runeWidth(r.unit))
+	}
+
+	// Columns are separated by 2 spaces.
+	if avail := width - valueW - unitW - 4; metricW > avail {
+		metricW = maxInt(avail, minMetricWidth)
+	}
+
+	printRow := func(r summaryRow) {
+		line := fmt.Sprintf("%-*s  %*s  %-*s", metricW, truncate(r.metric, metricW), valueW, r.value, unitW, r.unit)
+		out.Printf("%s\n", strings.TrimRight(line, " "))
+	}
+
+	printRow(header)
+	printRow(summaryRow{
+		strings.Repeat("-", metricW),
+		strings.Repeat("-", valueW),
+		strings.Repeat("-", unitW),
+	})
+	for _, r := range rows {
+		printRow(r)
+	}
+}
+
+// tableWidth returns the width available for the table. If out is a
+// terminal, the width is queried from the terminal, falling back to $COLUMNS,
+// otherwise a fixed width is used.
+func tableWidth(out output) int {
+	console, ok := out.(consoleOutput)
+	if !ok || !isTerminal(console.File) {
+		return defaultTableWidth
+	}
+
+	if columns, ok := terminalWidth(console.File); ok {
+		return columns
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return defaultTableWidth
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+func runeWidth(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// truncate shortens s to at most n runes, replacing the end with "..." if
+// there is room. s is only cut on rune boundaries.
+func truncate(s string, n int) string {
+	if runeWidth(s) <= n {
+		return s
+	}
+	if n <= 3 {
+		return prefixRunes(s, n)
+	}
+	return prefixRunes(s, n-3) + "..."
+}
+
+// prefixRunes returns the first n runes of s.
+func prefixRunes(s string, n int) string {
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yarpc/yab/statsd"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func knownBenchmarkState() *benchmarkState {
+	state := newBenchmarkState(statsd.Noop)
+	for i := 1; i <= 3; i++ {
+		state.recordLatency(time.Duration(i) * time.Millisecond)
+	}
+	state.recordError(errors.New("timeout"))
+	return state
+}
+
+func TestPrintTable(t *testing.T) {
+	buf, out := getOutput(t)
+	printTable(out, knownBenchmarkState().summaryRows(2*time.Second), defaultTableWidth)
+
+	want := []summaryRow{
+		{"Elapsed time", "2.000", "s"},
+		{"Total requests", "4", "requests"},
+		{"RPS", "2.00", "requests/s"},
+		{"Latency p50", "2.000", "ms"},
+		{"Latency p90", "2.800", "ms"},
+		{"Latency p95", "2.900", "ms"},
+		{"Latency p99", "2.980", "ms"},
+		{"Latency p99.9", "2.998", "ms"},
+		{"Latency p99.95", "2.999", "ms"},
+		{"Latency p100", "3.000", "ms"},
+		{"Total errors", "1", "errors"},
+		{"Error rate", "25.0000", "%"},
+		{"Error: timeout", "1", "errors"},
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, len(want)+2, "Expected header, separator and a line per metric")
+	assert.Equal(t, "Metric            Value  Unit", lines[0], "Unexpected header")
+	assert.Equal(t, "--------------  -------  ----------", lines[1], "Unexpected separator")
+
+	// Every row should use the same column offsets as the separator.
+	cols := strings.Split(lines[1], "  ")
+	require.Len(t, cols, 3, "Expected 3 columns")
+	valueStart := len(cols[0]) + 2
+	unitStart := valueStart + len(cols[1]) + 2
+	for i, w := range want {
+		line := lines[i+2]
+		require.True(t, len(line) > unitStart, "Line %q is too short", line)
+		assert.Equal(t, w.metric, strings.TrimRight(line[:len(cols[0])], " "), "Unexpected metric in %q", line)
+		assert.Equal(t, w.value, strings.TrimLeft(line[valueStart:unitStart-2], " "), "Value should be right-aligned in %q", line)
+		assert.Equal(t, w.unit, line[unitStart:], "Unexpected unit in %q", line)
+	}
+}
+
+func TestPrintTableNarrow(t *testing.T) {
+	const width = 30
+	buf, out := getOutput(t)
+	printTable(out, knownBenchmarkState().summaryRows(time.Second), width)
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		assert.True(t, len(line) <= width, "Line %q exceeds width %v", line, width)
+	}
+	assert.Contains(t, buf.String(), "Total ...", "Long metric names should be truncated")
+}
+
+func TestPrintTableNonASCII(t *testing.T) {
+	rows := []summaryRow{
+		{"Error: délai", "1", "errors"},
+		{"RPS", "2.00", "requests/s"},
+	}
+
+	buf, out := getOutput(t)
+	printTable(out, rows, defaultTableWidth)
+	assert.Equal(t, strings.Join([]string{
+		"Metric        Value  Unit",
+		"------------  -----  ----------",
+		"Error: délai      1  errors",
+		"RPS            2.00  requests/s",
+	}, "\n")+"\n", buf.String(), "Columns should be aligned by rune width")
+
+	buf, out = getOutput(t)
+	printTable(out, []summaryRow{{"Error: délai dépassé", "1", "errors"}}, 27)
+	assert.Contains(t, buf.String(), "Error: dé...      1  errors", "Metric should be truncated by runes")
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		assert.True(t, utf8.RuneCountInString(line) <= 27, "Line %q exceeds width", line)
+	}
+}
+
+func TestTableWidth(t *testing.T) {
+	_, out := getOutput(t)
+	assert.Equal(t, defaultTableWidth, tableWidth(out), "Non-console output should use the fixed width")
+
+	f := writeFile(t, "not-a-tty", "")
+	file, err := os.Open(f)
+	require.NoError(t, err, "Failed to open file")
+	defer file.Close()
+	assert.Equal(t, defaultTableWidth, tableWidth(consoleOutput{file}), "Files should use the fixed width")
+
+	_, ok := terminalWidth(file)
+	assert.False(t, ok, "Files should not report a terminal width")
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"metric", 10, "metric"},
+		{"metric", 6, "metric"},
+		{"metric", 5, "me..."},
+		{"metric", 2, "me"},
+		{"délai", 5, "délai"},
+		{"délai", 4, "d..."},
+		{"délai", 2, "dé"},
+		{"日本語のテキスト", 6, "日本語..."},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, truncate(tt.s, tt.n), "truncate(%q, %v)", tt.s, tt.n)
+	}
+}
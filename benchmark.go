@@ -22,6 +22,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"runtime"
@@ -36,6 +37,7 @@ import (
 var (
 	errNegativeDuration = errors.New("duration cannot be negative")
 	errNegativeMaxReqs  = errors.New("max requests cannot be negative")
+	errHeatmapInterval  = errors.New("heatmap interval must be positive")
 )
 
 // setGoMaxProcs sets runtime.GOMAXPROCS if the option is set
@@ -56,47 +58,118 @@ func (o BenchmarkOptions) getNumConnections(goMaxProcs int) int {
 	return goMaxProcs * 2
 }
 
-func (o BenchmarkOptions) validate() error {
+func (o BenchmarkOptions) validate(rOpts RequestOptions) error {
 	if o.MaxDuration < 0 {
 		return errNegativeDuration
 	}
 	if o.MaxRequests < 0 {
 		return errNegativeMaxReqs
 	}
+	if o.MaxInflight < 0 {
+		return errNegativeMaxInflight
+	}
+	if o.MaxInflight > 0 && o.ConcurrencyProfile != "" {
+		return errInflightOptions
+	}
+	if o.Scenario != "" {
+		if rOpts.MethodName != "" || rOpts.RequestJSON != "" || rOpts.RequestFile != "" {
+			return errScenarioRequest
+		}
+		if rOpts.ThriftTypeTagged {
+			// Type tags wrap response values, so steps cannot reference them.
+			return errScenarioTypeTagged
+		}
+	}
+	if o.HeatmapFeed != "" && o.HeatmapInterval <= 0 {
+		return errHeatmapInterval
+	}
+	if o.ServerTimeField != "" {
+		if o.Scenario != "" {
+			return errServerTimeScenario
+		}
+		if rOpts.ThriftTypeTagged {
+			// Type tags wrap the server time, so it is no longer a number.
+			return errServerTimeTypeTagged
+		}
+		if o.ServerTimeUnit <= 0 {
+			return errServerTimeUnit
+		}
+	}
+	switch o.Format {
+	case "", formatPlain, formatTable:
+	default:
+		return fmt.Errorf("unknown benchmark format: %q", o.Format)
+	}
 
 	return nil
 }
 
 func (o BenchmarkOptions) enabled() bool {
 	// By default, benchmarks are disabled. At least MaxDuration or MaxRequests
-	// should not be 0 for the benchmark to start.
+	// should not be 0 for the benchmark to start, unless a concurrency profile
+	// is specified, which limits the duration of the benchmark.
 	// We guard for negative values in the options validate() method, called
 	// after entering the benchmark case.
-	return o.MaxDuration != 0 || o.MaxRequests != 0
+	return o.MaxDuration != 0 || o.MaxRequests != 0 || o.ConcurrencyProfile != ""
 }
 
-func runWorker(t transport.Transport, m benchmarkMethod, s *benchmarkState, run *limiter.Run) {
+func runWorker(t transport.Transport, m benchmarkMethod, s *benchmarkState, run *limiter.Run, inflight *limiter.Inflight, rec heatmapRecorder) {
 	for cur := run; cur.More(); {
-		latency, err := m.call(t)
+		if inflight != nil && !inflight.Acquire(run.Done()) {
+			return
+		}
+
+		var latency, serverTime time.Duration
+		var err error
+		switch {
+		case m.scenario != nil:
+			latency, err = m.scenario.call(t, s.steps, rec)
+		case m.serverTime != nil:
+			latency, serverTime, err = m.callWithServerTime(t)
+		default:
+			latency, err = m.call(t)
+		}
+		if rec != nil && m.scenario == nil {
+			rec(m.req.Method, latency, err)
+		}
+		if inflight != nil {
+			inflight.Release()
+		}
 		if err != nil {
 			s.recordError(err)
 			continue
 		}
 
 		s.recordLatency(latency)
+		if m.serverTime != nil {
+			s.recordOverhead(latency - serverTime)
+		}
 	}
 }
 
 func runBenchmark(out output, allOpts Options, m benchmarkMethod) {
 	opts := allOpts.BOpts
 
-	if err := opts.validate(); err != nil {
+	if err := opts.validate(allOpts.ROpts); err != nil {
 		out.Fatalf("Invalid benchmarking options: %v", err)
 	}
 	if !opts.enabled() {
 		return
 	}
 
+	var profile concurrencyProfile
+	if opts.ConcurrencyProfile != "" {
+		var err error
+		if profile, err = parseConcurrencyProfile(opts.ConcurrencyProfile); err != nil {
+			out.Fatalf("Failed to load concurrency profile: %v", err)
+		}
+
+		// Without an explicit duration, the benchmark runs for the length of the profile.
+		if opts.MaxDuration == 0 {
+			opts.MaxDuration = profile.totalDuration()
+		}
+	}
+
 	if opts.RPS > 0 && opts.MaxDuration > 0 {
 		// The RPS * duration in seconds may cap opts.MaxRequests.
 		rpsMax := int(float64(opts.RPS) * opts.MaxDuration.Seconds())
@@ -105,8 +178,18 @@ func runBenchmark(out output, allOpts Options, m benchmarkMethod) {
 		}
 	}
 
+	if opts.ServerTimeField != "" {
+		m.serverTime = newServerTimeField(opts.ServerTimeField, opts.ServerTimeUnit)
+	}
+
 	goMaxProcs := opts.setGoMaxProcs()
 	numConns := opts.getNumConnections(goMaxProcs)
+	if profile != nil {
+		// Make sure there are enough workers to reach the profile's peak concurrency.
+		if perConn := (profile.maxConcurrency() + numConns - 1) / numConns; perConn > opts.Concurrency {
+			opts.Concurrency = perConn
+		}
+	}
 	out.Printf("Benchmark parameters:\n")
 	out.Printf("  CPUs:            %v\n", goMaxProcs)
 	out.Printf("  Connections:     %v\n", numConns)
@@ -114,13 +197,33 @@ func runBenchmark(out output, allOpts Options, m benchmarkMethod) {
 	out.Printf("  Max requests:    %v\n", opts.MaxRequests)
 	out.Printf("  Max duration:    %v\n", opts.MaxDuration)
 	out.Printf("  Max RPS:         %v\n", opts.RPS)
+	if m.scenario != nil {
+		out.Printf("  Scenario steps:  %v\n", len(m.scenario.steps))
+	}
+	if profile != nil {
+		out.Printf("  Max in-flight:   %v (profile, %v segments)\n", profile.maxConcurrency(), len(profile))
+	} else if opts.MaxInflight > 0 {
+		out.Printf("  Max in-flight:   %v\n", opts.MaxInflight)
+	}
 
 	// Warm up number of connections.
-	connections, err := m.WarmTransports(numConns, allOpts.TOpts, opts.WarmupRequests)
+	connections, peers, err := m.WarmTransports(numConns, allOpts.TOpts, opts.WarmupRequests)
 	if err != nil {
 		out.Fatalf("Failed to warmup connections for benchmark: %v", err)
 	}
 
+	var feed *heatmapFeed
+	if opts.HeatmapFeed != "" {
+		w, closer, err := openHeatmapFeed(out, opts.HeatmapFeed)
+		if err != nil {
+			out.Fatalf("Failed to open heatmap feed: %v", err)
+		}
+		if closer != nil {
+			defer closer.Close()
+		}
+		feed = newHeatmapFeed(w)
+	}
+
 	statter, err := statsd.NewClient(opts.StatsdHostPort, allOpts.TOpts.ServiceName, allOpts.ROpts.MethodName)
 	if err != nil {
 		out.Fatalf("Failed to create statsd client for benchmark: %v", err)
@@ -130,20 +233,47 @@ func runBenchmark(out output, allOpts Options, m benchmarkMethod) {
 	states := make([]*benchmarkState, len(connections)*opts.Concurrency)
 	for i := range states {
 		states[i] = newBenchmarkState(statter)
+		if m.scenario != nil {
+			states[i].steps = m.scenario.newStepStates()
+		}
+	}
+
+	var inflight *limiter.Inflight
+	if profile != nil {
+		inflight = limiter.NewInflight(profile[0].Concurrency)
+	} else if opts.MaxInflight > 0 {
+		inflight = limiter.NewInflight(opts.MaxInflight)
 	}
 
 	run := limiter.New(opts.MaxRequests, opts.RPS, opts.MaxDuration)
 	stopOnInterrupt(out, run)
+	if profile != nil {
+		go profile.apply(inflight, run.Done())
+	}
+
+	stopFeed := make(chan struct{})
+	feedErr := make(chan error, 1)
+	if feed != nil {
+		go func() {
+			feedErr <- feed.run(opts.HeatmapInterval, stopFeed)
+		}()
+	}
 
 	start := time.Now()
 	for i, c := range connections {
 		for j := 0; j < opts.Concurrency; j++ {
 			state := states[i*opts.Concurrency+j]
 
+			// Each worker records to its own shard of the feed.
+			var rec heatmapRecorder
+			if feed != nil {
+				rec = feed.recorderFor(peers[i])
+			}
+
 			wg.Add(1)
 			go func(c transport.Transport) {
 				defer wg.Done()
-				runWorker(c, m, state, run)
+				runWorker(c, m, state, run, inflight, rec)
 			}(c)
 		}
 	}
@@ -154,14 +284,34 @@ func runBenchmark(out output, allOpts Options, m benchmarkMethod) {
 	wg.Wait()
 	total := time.Since(start)
 
+	if feed != nil {
+		close(stopFeed)
+		if err := <-feedErr; err != nil {
+			out.Printf("Failed to write heatmap feed: %v\n", err)
+		}
+	}
+
 	// Merge all the states into 0
 	overall := states[0]
 	for _, s := range states[1:] {
 		overall.merge(s)
 	}
 
+	if opts.Format == formatTable {
+		rows := overall.summaryRows(total)
+		if m.scenario != nil {
+			rows = append(rows, m.scenario.stepRows(overall.steps)...)
+		}
+		printTable(out, rows, tableWidth(out))
+		return
+	}
+
 	overall.printErrors(out)
 	overall.printLatencies(out)
+	overall.printOverheads(out)
+	if m.scenario != nil {
+		m.scenario.printStepLatencies(out, overall.steps)
+	}
 
 	out.Printf("Elapsed time:      %v\n", (total / time.Millisecond * time.Millisecond))
 	out.Printf("Total requests:    %v\n", overall.totalRequests)
@@ -112,6 +112,7 @@ func TestBenchmark(t *testing.T) {
 func TestRunBenchmarkErrors(t *testing.T) {
 	tests := []struct {
 		opts    BenchmarkOptions
+		rOpts   RequestOptions
 		wantErr string
 	}{
 		{
@@ -126,6 +127,97 @@ func TestRunBenchmarkErrors(t *testing.T) {
 			},
 			wantErr: "duration cannot be negative",
 		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests: 1,
+				MaxInflight: -1,
+			},
+			wantErr: "max in-flight cannot be negative",
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests:        1,
+				MaxInflight:        1,
+				ConcurrencyProfile: "testdata/valid.json",
+			},
+			wantErr: "do not specify --max-inflight and --concurrency-profile",
+		},
+		{
+			opts: BenchmarkOptions{
+				ConcurrencyProfile: "/fake/file",
+			},
+			wantErr: "failed to open concurrency profile",
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests: 1,
+				HeatmapFeed: "stdout",
+			},
+			wantErr: "heatmap interval must be positive",
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests:     1,
+				ServerTimeField: "result.time",
+				Scenario:        "scenario.yaml",
+			},
+			wantErr: errServerTimeScenario.Error(),
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests:     1,
+				ServerTimeField: "result.time",
+				ServerTimeUnit:  time.Millisecond,
+			},
+			rOpts:   RequestOptions{ThriftTypeTagged: true},
+			wantErr: errServerTimeTypeTagged.Error(),
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests:     1,
+				ServerTimeField: "result.time",
+			},
+			wantErr: errServerTimeUnit.Error(),
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests: 1,
+				Format:      "json",
+			},
+			wantErr: `unknown benchmark format: "json"`,
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests: 1,
+				Scenario:    "scenario.yaml",
+			},
+			rOpts:   RequestOptions{ThriftTypeTagged: true},
+			wantErr: errScenarioTypeTagged.Error(),
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests: 1,
+				Scenario:    "scenario.yaml",
+			},
+			rOpts:   RequestOptions{MethodName: "create"},
+			wantErr: errScenarioRequest.Error(),
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests: 1,
+				Scenario:    "scenario.yaml",
+			},
+			rOpts:   RequestOptions{RequestJSON: `{"name": "foo"}`},
+			wantErr: errScenarioRequest.Error(),
+		},
+		{
+			opts: BenchmarkOptions{
+				MaxRequests: 1,
+				Scenario:    "scenario.yaml",
+			},
+			rOpts:   RequestOptions{RequestFile: "request.json"},
+			wantErr: errScenarioRequest.Error(),
+		},
 	}
 
 	for _, tt := range tests {
@@ -136,7 +228,7 @@ func TestRunBenchmarkErrors(t *testing.T) {
 			},
 		}
 		m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
-		opts := Options{BOpts: tt.opts}
+		opts := Options{BOpts: tt.opts, ROpts: tt.rOpts}
 
 		var wg sync.WaitGroup
 		wg.Add(1)
@@ -151,3 +243,28 @@ func TestRunBenchmarkErrors(t *testing.T) {
 		assert.Contains(t, fatalMessage, tt.wantErr, "Missing error for %+v", tt.opts)
 	}
 }
+
+func TestBenchmarkTableFormat(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, methods.echo())
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+	buf, out := getOutput(t)
+	runBenchmark(out, Options{
+		BOpts: BenchmarkOptions{
+			MaxRequests: 10,
+			Connections: 2,
+			Concurrency: 1,
+			Format:      formatTable,
+		},
+		TOpts: s.transportOpts(),
+	}, m)
+
+	bufStr := buf.String()
+	assert.Contains(t, bufStr, "Max RPS", "Benchmark parameters should be printed")
+	assert.Contains(t, bufStr, "Metric")
+	assert.Contains(t, bufStr, "Latency p99.95")
+	assert.NotContains(t, bufStr, "Latencies:", "Plain summary should not be printed")
+	assert.NotContains(t, bufStr, "Total errors", "No errors expected")
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/yarpc/yab/limiter"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	errConcurrencyProfileEmpty = errors.New("concurrency profile must contain at least one segment")
+	errInflightOptions         = errors.New("do not specify --max-inflight and --concurrency-profile")
+	errNegativeMaxInflight     = errors.New("max in-flight cannot be negative")
+)
+
+// profileAfter waits for a segment to end. It can be replaced in tests.
+var profileAfter = time.After
+
+// concurrencySegment caps the number of in-flight requests to Concurrency
+// for the given Duration.
+type concurrencySegment struct {
+	Duration    time.Duration `yaml:"duration"`
+	Concurrency int           `yaml:"concurrency"`
+}
+
+// concurrencyProfile is an ordered list of segments that drive the
+// in-flight limit over the course of a benchmark.
+type concurrencyProfile []concurrencySegment
+
+func parseConcurrencyProfile(filename string) (concurrencyProfile, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open concurrency profile: %v", err)
+	}
+
+	var profile concurrencyProfile
+	if err := yaml.Unmarshal(contents, &profile); err != nil {
+		return nil, fmt.Errorf("concurrency profile should be a JSON or YAML list of segments: %v", err)
+	}
+
+	return profile, profile.validate()
+}
+
+func (p concurrencyProfile) validate() error {
+	if len(p) == 0 {
+		return errConcurrencyProfileEmpty
+	}
+
+	for i, s := range p {
+		if s.Duration <= 0 {
+			return fmt.Errorf("concurrency profile segment %v must have a positive duration", i)
+		}
+		if s.Concurrency <= 0 {
+			return fmt.Errorf("concurrency profile segment %v must have a positive concurrency", i)
+		}
+	}
+
+	return nil
+}
+
+// maxConcurrency returns the highest in-flight limit used by the profile.
+func (p concurrencyProfile) maxConcurrency() int {
+	max := 0
+	for _, s := range p {
+		if s.Concurrency > max {
+			max = s.Concurrency
+		}
+	}
+	return max
+}
+
+// totalDuration returns the sum of the durations of all segments.
+func (p concurrencyProfile) totalDuration() time.Duration {
+	var total time.Duration
+	for _, s := range p {
+		total += s.Duration
+	}
+	return total
+}
+
+// apply updates the in-flight limit as each segment starts until either
+// done is closed or all segments have been applied. The limit from the
+// last segment stays in effect after the profile ends.
+func (p concurrencyProfile) apply(inflight *limiter.Inflight, done <-chan struct{}) {
+	for _, s := range p {
+		inflight.SetLimit(s.Concurrency)
+
+		select {
+		case <-profileAfter(s.Duration):
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yarpc/yab/limiter"
+	"github.com/yarpc/yab/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go/raw"
+	"github.com/uber/tchannel-go/testutils"
+	"golang.org/x/net/context"
+)
+
+func TestParseConcurrencyProfile(t *testing.T) {
+	tests := []struct {
+		contents string
+		want     concurrencyProfile
+		wantErr  string
+	}{
+		{
+			contents: `[{"duration": "1s", "concurrency": 5}, {"duration": "500ms", "concurrency": 10}]`,
+			want: concurrencyProfile{
+				{Duration: time.Second, Concurrency: 5},
+				{Duration: 500 * time.Millisecond, Concurrency: 10},
+			},
+		},
+		{
+			contents: "- duration: 2s\n  concurrency: 3\n",
+			want: concurrencyProfile{
+				{Duration: 2 * time.Second, Concurrency: 3},
+			},
+		},
+		{
+			contents: "[]",
+			wantErr:  errConcurrencyProfileEmpty.Error(),
+		},
+		{
+			contents: `[{"duration": "1s", "concurrency": 0}]`,
+			wantErr:  "segment 0 must have a positive concurrency",
+		},
+		{
+			contents: `[{"duration": "1s", "concurrency": 1}, {"concurrency": 1}]`,
+			wantErr:  "segment 1 must have a positive duration",
+		},
+		{
+			contents: "not a list",
+			wantErr:  "should be a JSON or YAML list of segments",
+		},
+	}
+
+	for _, tt := range tests {
+		f := writeFile(t, "profile", tt.contents)
+		got, err := parseConcurrencyProfile(f)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "Expected error for %q", tt.contents) {
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error for %q", tt.contents)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, "Failed to parse %q", tt.contents) {
+			assert.Equal(t, tt.want, got, "Unexpected profile for %q", tt.contents)
+		}
+	}
+}
+
+func TestParseConcurrencyProfileMissingFile(t *testing.T) {
+	_, err := parseConcurrencyProfile("/fake/file")
+	require.Error(t, err, "Expected error for missing file")
+	assert.Contains(t, err.Error(), "failed to open concurrency profile")
+}
+
+// fakeSegmentTimer is a segment timer created by fakeProfileAfter.
+type fakeSegmentTimer struct {
+	d time.Duration
+	c chan time.Time
+}
+
+// fakeProfileAfter replaces profileAfter with timers that are sent to the
+// returned channel, and only fire when the test sends on them. The returned
+// func restores profileAfter.
+func fakeProfileAfter() (<-chan fakeSegmentTimer, func()) {
+	timers := make(chan fakeSegmentTimer)
+	profileAfter = func(d time.Duration) <-chan time.Time {
+		timer := fakeSegmentTimer{d, make(chan time.Time, 1)}
+		timers <- timer
+		return timer.c
+	}
+	return timers, func() { profileAfter = time.After }
+}
+
+func TestConcurrencyProfileApply(t *testing.T) {
+	timers, restore := fakeProfileAfter()
+	defer restore()
+
+	profile := concurrencyProfile{
+		{Duration: time.Second, Concurrency: 2},
+		{Duration: 2 * time.Second, Concurrency: 8},
+		{Duration: 3 * time.Second, Concurrency: 4},
+	}
+	assert.Equal(t, 8, profile.maxConcurrency(), "Unexpected max concurrency")
+	assert.Equal(t, 6*time.Second, profile.totalDuration(), "Unexpected total duration")
+
+	inflight := limiter.NewInflight(0)
+	done := make(chan struct{})
+	defer close(done)
+
+	applied := make(chan struct{})
+	go func() {
+		defer close(applied)
+		profile.apply(inflight, done)
+	}()
+
+	// Each segment's limit is set before waiting for the segment to end.
+	for i, s := range profile {
+		timer := <-timers
+		assert.Equal(t, s.Duration, timer.d, "Unexpected duration for segment %v", i)
+		assert.Equal(t, s.Concurrency, inflight.Limit(), "Unexpected limit in segment %v", i)
+		timer.c <- time.Time{}
+	}
+
+	// The last segment's limit should stay in effect.
+	<-applied
+	assert.Equal(t, 4, inflight.Limit(), "Unexpected limit after profile ended")
+}
+
+func TestConcurrencyProfileApplyDone(t *testing.T) {
+	timers, restore := fakeProfileAfter()
+	defer restore()
+
+	profile := concurrencyProfile{
+		{Duration: time.Second, Concurrency: 2},
+		{Duration: time.Second, Concurrency: 8},
+	}
+
+	inflight := limiter.NewInflight(0)
+	done := make(chan struct{})
+	applied := make(chan struct{})
+	go func() {
+		defer close(applied)
+		profile.apply(inflight, done)
+	}()
+
+	<-timers
+	close(done)
+	<-applied
+	assert.Equal(t, 2, inflight.Limit(), "Later segments should not be applied once done")
+}
+
+func TestBenchmarkConcurrencyProfile(t *testing.T) {
+	timers, restore := fakeProfileAfter()
+	defer restore()
+
+	var (
+		mu      sync.Mutex
+		current int
+		segment int
+		calls   [2]int
+		peaks   [2]int
+	)
+
+	s := newServer(t)
+	defer s.shutdown()
+	s.register(fooMethod, func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		mu.Lock()
+		current++
+		calls[segment]++
+		if current > peaks[segment] {
+			peaks[segment] = current
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		current--
+		mu.Unlock()
+		return &raw.Res{Arg2: args.Arg2, Arg3: args.Arg3}, nil
+	})
+
+	// callsIn waits until there have been n calls in the given segment.
+	callsIn := func(seg, n int) bool {
+		deadline := time.Now().Add(testutils.Timeout(time.Second))
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			got := calls[seg]
+			mu.Unlock()
+			if got >= n {
+				return true
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return false
+	}
+
+	profileFile := writeFile(t, "profile", `[{"duration": "1m", "concurrency": 1}, {"duration": "1m", "concurrency": 3}]`)
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+
+	buf, out := getOutput(t)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		runBenchmark(out, Options{
+			BOpts: BenchmarkOptions{
+				ConcurrencyProfile: profileFile,
+				MaxRequests:        200,
+				Connections:        5,
+				Concurrency:        1,
+			},
+			TOpts: s.transportOpts(),
+		}, m)
+	}()
+
+	// The first segment only ends once it has been observed, so the limit
+	// change does not depend on how quickly calls are made.
+	first := <-timers
+	require.True(t, callsIn(0, 20), "Expected calls in the first segment")
+	mu.Lock()
+	segment = 1
+	mu.Unlock()
+	first.c <- time.Time{}
+
+	// The second segment lasts until the benchmark makes all its requests.
+	<-timers
+	<-finished
+
+	assert.Contains(t, buf.String(), "Max in-flight:   3 (profile, 2 segments)")
+	assert.Contains(t, buf.String(), "Max duration:    2m0s")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, peaks[0], "First segment should be limited to 1 in-flight call")
+	assert.True(t, calls[1] > 0, "Expected calls in the second segment")
+	assert.True(t, peaks[1] > 1, "Second segment should raise the in-flight limit, got %v", peaks[1])
+	assert.True(t, peaks[1] <= 3, "Second segment exceeded its limit of 3, got %v", peaks[1])
+}
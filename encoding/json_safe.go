@@ -0,0 +1,187 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yarpc/yab/sorted"
+	"github.com/yarpc/yab/transport"
+)
+
+// jsonSafeError is returned when a field in the response cannot be
+// represented in JSON without loss.
+type jsonSafeError struct {
+	path   string
+	reason string
+}
+
+func (e jsonSafeError) Error() string {
+	return fmt.Sprintf("response field %q is not JSON safe: %v", e.path, e.reason)
+}
+
+type jsonSafeSerializer struct {
+	Serializer
+}
+
+// WithJSONSafeCheck returns a Serializer that fails if a response cannot be
+// serialized to valid UTF-8 JSON without loss, e.g. because it contains binary
+// data or strings that are not valid UTF-8.
+func WithJSONSafeCheck(s Serializer) Serializer {
+	return jsonSafeSerializer{s}
+}
+
+func (s jsonSafeSerializer) Response(res *transport.Response) (interface{}, error) {
+	// The JSON decoder replaces invalid UTF-8 with U+FFFD, so the decoded
+	// response would look safe even though the body was not.
+	if s.Encoding() == JSON && !utf8.Valid(res.Body) {
+		return nil, jsonSafeError{"response", fmt.Sprintf("body is not valid UTF-8 at offset %v", invalidUTF8Offset(res.Body))}
+	}
+
+	v, err := s.Serializer.Response(res)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckJSONSafe(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s jsonSafeSerializer) CheckSuccess(res *transport.Response) error {
+	if err := s.Serializer.CheckSuccess(res); err != nil {
+		return err
+	}
+
+	_, err := s.Response(res)
+	return err
+}
+
+// invalidUTF8Offset returns the offset of the first byte in bs that is not
+// part of a valid UTF-8 sequence.
+func invalidUTF8Offset(bs []byte) int {
+	for i := 0; i < len(bs); {
+		r, size := utf8.DecodeRune(bs[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return len(bs)
+}
+
+// CheckJSONSafe returns an error pointing at the first field in v that cannot
+// be represented in JSON without loss.
+func CheckJSONSafe(v interface{}) error {
+	if err := checkJSONSafe("", v); err != nil {
+		return err
+	}
+
+	if _, err := json.Marshal(v); err != nil {
+		return jsonSafeError{"response", err.Error()}
+	}
+	return nil
+}
+
+func checkJSONSafe(path string, v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		if !utf8.ValidString(v) {
+			return jsonSafeError{displayPath(path), "string is not valid UTF-8"}
+		}
+	case []byte:
+		return jsonSafeError{displayPath(path), "binary data cannot be represented as a JSON string"}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return jsonSafeError{displayPath(path), fmt.Sprintf("%v cannot be represented as a JSON number", v)}
+		}
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		for _, k := range sorted.MapKeys(v) {
+			if !utf8.ValidString(k) {
+				return jsonSafeError{displayPath(path), fmt.Sprintf("key %q is not valid UTF-8", k)}
+			}
+			if err := checkJSONSafe(joinPath(path, k), v[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if err := checkJSONSafe(fmt.Sprintf("%v[%v]", displayPath(path), i), item); err != nil {
+				return err
+			}
+		}
+	default:
+		return checkJSONSafeStruct(path, v)
+	}
+	return nil
+}
+
+// checkJSONSafeStruct checks the exported fields of struct values, such as
+// the type-tagged values returned for --type-tagged responses.
+func checkJSONSafeStruct(path string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		if err := checkJSONSafe(joinPath(path, name), rv.Field(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "response"
+	}
+	return path
+}
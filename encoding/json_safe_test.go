@@ -0,0 +1,232 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package encoding
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/yarpc/yab/internal/thrifttest"
+	"github.com/yarpc/yab/thrift"
+	"github.com/yarpc/yab/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/thriftrw/protocol"
+	"go.uber.org/thriftrw/wire"
+)
+
+type typedValue struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+	skip  interface{}
+}
+
+func TestCheckJSONSafe(t *testing.T) {
+	tests := []struct {
+		v      interface{}
+		errMsg string
+	}{
+		{v: nil},
+		{v: "hello world"},
+		{v: map[string]interface{}{}},
+		{
+			v: map[string]interface{}{
+				"result": map[string]interface{}{
+					"items": []interface{}{
+						map[string]interface{}{"name": "a", "count": int32(1)},
+					},
+				},
+			},
+		},
+		{
+			v:      "\xff",
+			errMsg: `response field "response" is not JSON safe: string is not valid UTF-8`,
+		},
+		{
+			v: map[string]interface{}{
+				"result": map[string]interface{}{
+					"items": []interface{}{
+						map[string]interface{}{"name": "a"},
+						map[string]interface{}{"name": "b\xff"},
+					},
+				},
+			},
+			errMsg: `response field "result.items[1].name" is not JSON safe: string is not valid UTF-8`,
+		},
+		{
+			v:      map[string]interface{}{"data": []byte("binary")},
+			errMsg: `response field "data" is not JSON safe: binary data cannot be represented`,
+		},
+		{
+			v:      map[string]interface{}{"ratio": math.NaN()},
+			errMsg: `response field "ratio" is not JSON safe: NaN cannot be represented`,
+		},
+		{
+			v:      map[string]interface{}{"ratio": math.Inf(1)},
+			errMsg: `response field "ratio" is not JSON safe: +Inf cannot be represented`,
+		},
+		{
+			v:      map[string]interface{}{"a\xffb": "value"},
+			errMsg: `response field "response" is not JSON safe: key "a\xffb" is not valid UTF-8`,
+		},
+		{
+			v:      []interface{}{"ok", []byte{0}},
+			errMsg: `response field "response[1]" is not JSON safe: binary data`,
+		},
+		{
+			v:      map[string]interface{}{"name": typedValue{Type: "string", Value: "\xfe"}},
+			errMsg: `response field "name.value" is not JSON safe: string is not valid UTF-8`,
+		},
+		{
+			v: map[string]interface{}{"name": typedValue{Type: "string", Value: "ok", skip: []byte{0}}},
+		},
+		{
+			v:      map[string]interface{}{"ch": make(chan int)},
+			errMsg: `response field "response" is not JSON safe: json: unsupported type`,
+		},
+	}
+
+	for _, tt := range tests {
+		err := CheckJSONSafe(tt.v)
+		if tt.errMsg == "" {
+			assert.NoError(t, err, "CheckJSONSafe(%#v) failed", tt.v)
+			continue
+		}
+
+		if assert.Error(t, err, "CheckJSONSafe(%#v) should fail", tt.v) {
+			assert.Contains(t, err.Error(), tt.errMsg, "Unexpected error for %#v", tt.v)
+		}
+	}
+}
+
+func TestWithJSONSafeCheck(t *testing.T) {
+	tests := []struct {
+		serializer Serializer
+		body       []byte
+		errMsg     string
+	}{
+		{
+			serializer: NewJSON("method"),
+			body:       []byte(`{"name": "hello"}`),
+		},
+		{
+			serializer: NewJSON("method"),
+			body:       []byte(`{`),
+			errMsg:     "failed to parse JSON",
+		},
+		{
+			serializer: NewJSON("method"),
+			body:       []byte("{\"name\": \"a\xffb\"}"),
+			errMsg:     `response field "response" is not JSON safe: body is not valid UTF-8 at offset 11`,
+		},
+		{
+			serializer: NewRaw("method"),
+			body:       []byte("raw"),
+			errMsg:     `response field "response" is not JSON safe: binary data`,
+		},
+	}
+
+	for _, tt := range tests {
+		serializer := WithJSONSafeCheck(tt.serializer)
+		assert.Equal(t, tt.serializer.Encoding(), serializer.Encoding(), "Encoding mismatch")
+
+		res := &transport.Response{Body: tt.body}
+		got, err := serializer.Response(res)
+		checkErr := serializer.CheckSuccess(res)
+		if tt.errMsg == "" {
+			require.NoError(t, err, "Response failed for %s", tt.body)
+			assert.NoError(t, checkErr, "CheckSuccess failed for %s", tt.body)
+			assert.Equal(t, map[string]interface{}{"name": "hello"}, got)
+			continue
+		}
+
+		if assert.Error(t, err, "Response should fail for %s", tt.body) {
+			assert.Contains(t, err.Error(), tt.errMsg, "Unexpected Response error")
+		}
+		if assert.Error(t, checkErr, "CheckSuccess should fail for %s", tt.body) {
+			assert.Contains(t, checkErr.Error(), tt.errMsg, "Unexpected CheckSuccess error")
+		}
+	}
+}
+
+func TestWithJSONSafeCheckThrift(t *testing.T) {
+	parsed := thrifttest.Parse(t, `
+    struct Item {
+      1: optional string name
+      2: optional binary data
+    }
+    service Store {
+      Item get()
+    }
+  `)
+	spec := parsed.Services["Store"].Functions["get"]
+	serializer := WithJSONSafeCheck(thriftSerializer{"Store::get", spec, thrift.Options{}})
+
+	responseFor := func(fields ...wire.Field) *transport.Response {
+		result := wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+			{ID: 0, Value: wire.NewValueStruct(wire.Struct{Fields: fields})},
+		}})
+
+		var buf bytes.Buffer
+		require.NoError(t, protocol.Binary.Encode(result, &buf), "Failed to encode response")
+		return &transport.Response{Body: buf.Bytes()}
+	}
+
+	tests := []struct {
+		msg    string
+		res    *transport.Response
+		errMsg string
+	}{
+		{
+			msg: "valid UTF-8 string",
+			res: responseFor(wire.Field{ID: 1, Value: wire.NewValueString("héllo")}),
+		},
+		{
+			msg:    "invalid UTF-8 string",
+			res:    responseFor(wire.Field{ID: 1, Value: wire.NewValueString("a\xffb")}),
+			errMsg: `response field "result.name" is not JSON safe: string is not valid UTF-8`,
+		},
+		{
+			msg:    "binary field",
+			res:    responseFor(wire.Field{ID: 2, Value: wire.NewValueBinary([]byte{0, 1})}),
+			errMsg: `response field "result.data" is not JSON safe: binary data`,
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := serializer.Response(tt.res)
+		checkErr := serializer.CheckSuccess(tt.res)
+		if tt.errMsg == "" {
+			assert.NoError(t, err, "%v: Response failed", tt.msg)
+			assert.NoError(t, checkErr, "%v: CheckSuccess failed", tt.msg)
+			continue
+		}
+
+		if assert.Error(t, err, "%v: Response should fail", tt.msg) {
+			assert.Contains(t, err.Error(), tt.errMsg, "%v: unexpected Response error", tt.msg)
+		}
+		if assert.Error(t, checkErr, "%v: CheckSuccess should fail", tt.msg) {
+			assert.Contains(t, checkErr.Error(), tt.errMsg, "%v: unexpected CheckSuccess error", tt.msg)
+		}
+	}
+}
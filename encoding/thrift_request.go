@@ -23,6 +23,7 @@ package encoding
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/yarpc/yab/sorted"
@@ -37,6 +38,11 @@ const _multiplexedSeparator = ":"
 
 var defaultOpts = thrift.Options{UseEnvelopes: true}
 
+// ErrCheckMethodNoEnvelopes is returned if the envelope method check is
+// enabled when Thrift envelopes are disabled.
+var ErrCheckMethodNoEnvelopes = errors.New("--thrift-check-method-name requires Thrift envelopes, " +
+	"which are disabled for TChannel and by --disable-thrift-envelope")
+
 type thriftSerializer struct {
 	methodName string
 	spec       *compile.FunctionSpec
@@ -128,6 +134,32 @@ func (e thriftSerializer) WithoutEnvelopes() Serializer {
 	return e
 }
 
+// WithEnvelopeMethodCheck returns a serializer that checks the method name in
+// the response envelope. Mismatches are written to warnings if it is non-nil,
+// and fail the response otherwise.
+func (e thriftSerializer) WithEnvelopeMethodCheck(warnings io.Writer) (Serializer, error) {
+	if !e.opts.UseEnvelopes {
+		return nil, ErrCheckMethodNoEnvelopes
+	}
+
+	// We're modifying a copy of e.
+	e.opts.CheckEnvelopeMethod = true
+	e.opts.EnvelopeMethodWarnings = warnings
+	return e, nil
+}
+
+func (e thriftSerializer) WithMaxStringBytes(n int) Serializer {
+	// We're modifying a copy of e.
+	e.opts.MaxStringBytes = n
+	return e
+}
+
+func (e thriftSerializer) WithTypeTags() Serializer {
+	// We're modifying a copy of e.
+	e.opts.TypeTagged = true
+	return e
+}
+
 func findMethod(service *compile.ServiceSpec, methodName string) (*compile.FunctionSpec, error) {
 	functions := service.Functions
 
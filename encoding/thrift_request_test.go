@@ -21,9 +21,12 @@
 package encoding
 
 import (
+	"bytes"
+	"encoding/json"
 	"testing"
 
 	"github.com/yarpc/yab/internal/thrifttest"
+	"github.com/yarpc/yab/transport"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -315,3 +318,70 @@ func TestWithoutEnvelopes(t *testing.T) {
 		assert.Equal(t, tt.want, got.Body, "%v: got unexpected bytes", tt.desc)
 	}
 }
+
+func TestWithTypeTags(t *testing.T) {
+	serializer, err := NewThrift(validThrift, "Simple::bar", false /* multiplexed */)
+	require.NoError(t, err, "Failed to create serializer")
+	serializer = serializer.(thriftSerializer).WithoutEnvelopes()
+
+	res := &transport.Response{
+		Body: []byte{
+			0x08, 0x00, 0x00, // type = i32 | field ID = 0
+			0x00, 0x00, 0x00, 0x05, // 5
+			0x00, // stop
+		},
+	}
+
+	got, err := serializer.Response(res)
+	require.NoError(t, err, "Failed to deserialize response")
+	assert.Equal(t, map[string]interface{}{"result": int32(5)}, got, "Unexpected plain response")
+
+	tagged := serializer.(thriftSerializer).WithTypeTags()
+	got, err = tagged.Response(res)
+	require.NoError(t, err, "Failed to deserialize response")
+	bs, err := json.Marshal(got)
+	require.NoError(t, err, "Failed to marshal response")
+	assert.JSONEq(t, `{"result": {"type": "i32", "value": 5}}`, string(bs), "Unexpected tagged response")
+}
+
+func TestWithEnvelopeMethodCheck(t *testing.T) {
+	serializer, err := NewThrift(validThrift, "Simple::foo", false /* multiplexed */)
+	require.NoError(t, err, "Failed to create serializer")
+
+	replyFor := func(method string) *transport.Response {
+		body := []byte{
+			0x80, 0x01, 0x00, 0x02, // version | type = 2 | reply
+			0x00, 0x00, 0x00, byte(len(method)),
+		}
+		body = append(body, method...)
+		body = append(body,
+			0x00, 0x00, 0x00, 0x00, // seqID
+			0x00, // empty struct
+		)
+		return &transport.Response{Body: body}
+	}
+
+	assert.NoError(t, serializer.CheckSuccess(replyFor("bar")), "Mismatched method should be ignored by default")
+
+	checked, err := serializer.(thriftSerializer).WithEnvelopeMethodCheck(nil /* warnings */)
+	require.NoError(t, err, "Failed to enable envelope method check")
+	assert.NoError(t, checked.CheckSuccess(replyFor("foo")), "Matching method should succeed")
+
+	err = checked.CheckSuccess(replyFor("bar"))
+	if assert.Error(t, err, "Mismatched method should fail") {
+		assert.Contains(t, err.Error(), `response envelope method "bar" does not match requested method "foo"`)
+	}
+
+	var warnings bytes.Buffer
+	warned, err := serializer.(thriftSerializer).WithEnvelopeMethodCheck(&warnings)
+	require.NoError(t, err, "Failed to enable envelope method warnings")
+	assert.NoError(t, warned.CheckSuccess(replyFor("bar")), "Mismatched method should not fail")
+	assert.Empty(t, warnings.String(), "CheckSuccess should not warn")
+	_, err = warned.Response(replyFor("bar"))
+	assert.NoError(t, err, "Mismatched method should only warn")
+	assert.Contains(t, warnings.String(), `response envelope method "bar" does not match requested method "foo"`)
+
+	noEnvelopes := serializer.(thriftSerializer).WithoutEnvelopes()
+	_, err = noEnvelopes.(thriftSerializer).WithEnvelopeMethodCheck(nil /* warnings */)
+	assert.Equal(t, ErrCheckMethodNoEnvelopes, err, "Check should require envelopes")
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// heatmapRecorder records the result of a single call to method.
+type heatmapRecorder func(method string, latency time.Duration, err error)
+
+type heatmapKey struct {
+	method string
+	peer   string
+}
+
+type heatmapCell struct {
+	requests  int
+	latencies []time.Duration
+}
+
+// heatmapTuple is emitted for each method and peer per window.
+type heatmapTuple struct {
+	Method string  `json:"method"`
+	Peer   string  `json:"peer"`
+	Window int     `json:"window"`
+	RPS    float64 `json:"rps"`
+	P99    float64 `json:"p99_ms"`
+}
+
+// heatmapFeed aggregates calls by method and peer, and periodically writes
+// the per-window throughput and p99 latency as JSON to w.
+type heatmapFeed struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	window  int
+	shards  []*heatmapShard
+
+	// seen contains every method and peer that has had calls, so that
+	// windows without calls are reported as empty cells.
+	seen map[heatmapKey]struct{}
+}
+
+// heatmapShard aggregates the calls made by a single worker, so that
+// workers do not contend with each other when recording calls.
+type heatmapShard struct {
+	mu    sync.Mutex
+	peer  string
+	cells map[string]*heatmapCell
+}
+
+func newHeatmapFeed(w io.Writer) *heatmapFeed {
+	return &heatmapFeed{
+		encoder: json.NewEncoder(w),
+		seen:    make(map[heatmapKey]struct{}),
+	}
+}
+
+// openHeatmapFeed returns the writer for the heatmap feed destination, which
+// is either "stdout" or a file path. The closer is nil for stdout.
+func openHeatmapFeed(out output, dest string) (io.Writer, io.Closer, error) {
+	if dest == "stdout" {
+		return out, nil, nil
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f, nil
+}
+
+// recorderFor returns a heatmapRecorder for calls made to peer. Each worker
+// should use its own recorder.
+func (f *heatmapFeed) recorderFor(peer string) heatmapRecorder {
+	shard := &heatmapShard{
+		peer:  peer,
+		cells: make(map[string]*heatmapCell),
+	}
+
+	f.mu.Lock()
+	f.shards = append(f.shards, shard)
+	f.mu.Unlock()
+
+	return shard.record
+}
+
+func (s *heatmapShard) record(method string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cell, ok := s.cells[method]
+	if !ok {
+		cell = &heatmapCell{}
+		s.cells[method] = cell
+	}
+
+	cell.requests++
+	if err == nil {
+		cell.latencies = append(cell.latencies, latency)
+	}
+}
+
+// take returns the calls recorded since the last take.
+func (s *heatmapShard) take() map[string]*heatmapCell {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cells := s.cells
+	s.cells = make(map[string]*heatmapCell, len(cells))
+	return cells
+}
+
+// flush writes a tuple for every method and peer that has had calls in the
+// current window or an earlier one, for the current window which lasted for
+// elapsed, and starts a new window.
+func (f *heatmapFeed) flush(elapsed time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cells := make(map[heatmapKey]*heatmapCell)
+	for _, shard := range f.shards {
+		for method, shardCell := range shard.take() {
+			key := heatmapKey{method, shard.peer}
+			f.seen[key] = struct{}{}
+
+			cell, ok := cells[key]
+			if !ok {
+				cell = &heatmapCell{}
+				cells[key] = cell
+			}
+			cell.requests += shardCell.requests
+			cell.latencies = append(cell.latencies, shardCell.latencies...)
+		}
+	}
+
+	window := f.window
+	f.window++
+
+	keys := make([]heatmapKey, 0, len(f.seen))
+	for k := range f.seen {
+		keys = append(keys, k)
+	}
+	sort.Sort(byHeatmapKey(keys))
+
+	for _, k := range keys {
+		tuple := heatmapTuple{
+			Method: k.method,
+			Peer:   k.peer,
+			Window: window,
+		}
+
+		if cell, ok := cells[k]; ok {
+			sort.Sort(byDuration(cell.latencies))
+			p99 := (&benchmarkState{latencies: cell.latencies}).getQuantile(0.99)
+			tuple.RPS = float64(cell.requests) / elapsed.Seconds()
+			tuple.P99 = float64(p99) / float64(time.Millisecond)
+		}
+
+		if err := f.encoder.Encode(tuple); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run flushes the feed every interval until stop is closed, at which point
+// the last partial window is flushed.
+func (f *heatmapFeed) run(interval time.Duration, stop <-chan struct{}) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	windowStart := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return f.flush(time.Since(windowStart))
+		}
+
+		now := time.Now()
+		if err := f.flush(now.Sub(windowStart)); err != nil {
+			return err
+		}
+		windowStart = now
+	}
+}
+
+type byHeatmapKey []heatmapKey
+
+func (p byHeatmapKey) Len() int      { return len(p) }
+func (p byHeatmapKey) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byHeatmapKey) Less(i, j int) bool {
+	if p[i].method != p[j].method {
+		return p[i].method < p[j].method
+	}
+	return p[i].peer < p[j].peer
+}
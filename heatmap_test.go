@@ -0,0 +1,183 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/yarpc/yab/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeHeatmapTuples(t *testing.T, bs []byte) []heatmapTuple {
+	var tuples []heatmapTuple
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		var tuple heatmapTuple
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &tuple), "Failed to decode %s", scanner.Bytes())
+		tuples = append(tuples, tuple)
+	}
+	require.NoError(t, scanner.Err(), "Failed to read heatmap feed")
+	return tuples
+}
+
+func TestHeatmapFeedFlush(t *testing.T) {
+	buf := &bytes.Buffer{}
+	feed := newHeatmapFeed(buf)
+
+	rec1 := feed.recorderFor("peer1")
+	rec2 := feed.recorderFor("peer2")
+	for i := 1; i <= 100; i++ {
+		rec1("m1", time.Duration(i)*time.Millisecond, nil)
+	}
+	rec1("m1", time.Second, errors.New("failed"))
+	rec2("m1", 5*time.Millisecond, nil)
+	rec2("m1", 7*time.Millisecond, nil)
+	rec1("m2", 3*time.Millisecond, nil)
+
+	require.NoError(t, feed.flush(2*time.Second), "Flush failed")
+
+	// Calls in the next window should not include the previous window's calls.
+	rec2("m2", 4*time.Millisecond, nil)
+	require.NoError(t, feed.flush(time.Second), "Flush failed")
+
+	tuples := decodeHeatmapTuples(t, buf.Bytes())
+	require.Len(t, tuples, 7, "Expected one tuple per method, peer and window")
+
+	assert.Equal(t, heatmapTuple{Method: "m1", Peer: "peer1", Window: 0, RPS: 50.5}, withoutP99(tuples[0]))
+	assert.InDelta(t, 99.01, tuples[0].P99, 0.001, "Unexpected p99 for m1/peer1")
+
+	assert.Equal(t, heatmapTuple{Method: "m1", Peer: "peer2", Window: 0, RPS: 1}, withoutP99(tuples[1]))
+	assert.InDelta(t, 6.98, tuples[1].P99, 0.001, "Unexpected p99 for m1/peer2")
+
+	assert.Equal(t, heatmapTuple{Method: "m2", Peer: "peer1", Window: 0, RPS: 0.5, P99: 3}, tuples[2])
+
+	// Methods and peers without calls in a window are reported as empty cells.
+	assert.Equal(t, []heatmapTuple{
+		{Method: "m1", Peer: "peer1", Window: 1},
+		{Method: "m1", Peer: "peer2", Window: 1},
+		{Method: "m2", Peer: "peer1", Window: 1},
+		{Method: "m2", Peer: "peer2", Window: 1, RPS: 1, P99: 4},
+	}, tuples[3:], "Unexpected tuples for the second window")
+}
+
+func TestHeatmapFeedMergesRecorders(t *testing.T) {
+	buf := &bytes.Buffer{}
+	feed := newHeatmapFeed(buf)
+
+	// Workers for the same peer use separate recorders, which are merged.
+	recs := []heatmapRecorder{feed.recorderFor("peer"), feed.recorderFor("peer")}
+	for i, rec := range recs {
+		rec("m", time.Duration(i+1)*time.Millisecond, nil)
+	}
+	require.NoError(t, feed.flush(time.Second), "Flush failed")
+
+	tuples := decodeHeatmapTuples(t, buf.Bytes())
+	require.Len(t, tuples, 1, "Expected a single tuple for the peer")
+	assert.Equal(t, heatmapTuple{Method: "m", Peer: "peer", Window: 0, RPS: 2}, withoutP99(tuples[0]))
+	assert.InDelta(t, 1.99, tuples[0].P99, 0.001, "Unexpected p99")
+}
+
+func withoutP99(tuple heatmapTuple) heatmapTuple {
+	tuple.P99 = 0
+	return tuple
+}
+
+func TestHeatmapFeedRun(t *testing.T) {
+	buf := &bytes.Buffer{}
+	feed := newHeatmapFeed(buf)
+	rec := feed.recorderFor("peer")
+	stop := make(chan struct{})
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- feed.run(10*time.Millisecond, stop)
+	}()
+
+	rec("m", time.Millisecond, nil)
+	time.Sleep(25 * time.Millisecond)
+	rec("m", time.Millisecond, nil)
+	close(stop)
+	require.NoError(t, <-errC, "run failed")
+
+	tuples := decodeHeatmapTuples(t, buf.Bytes())
+	require.True(t, len(tuples) >= 2, "Expected calls in at least two windows, got %v", tuples)
+	assert.Equal(t, 0, tuples[0].Window, "First tuple should be in the first window")
+	assert.True(t, tuples[len(tuples)-1].Window > 0, "Last call should be in a later window")
+}
+
+func TestBenchmarkHeatmapFeed(t *testing.T) {
+	servers := []*server{newServer(t), newServer(t)}
+	hostPorts := make(map[string]bool)
+	for _, s := range servers {
+		defer s.shutdown()
+		s.register(fooMethod, methods.echo())
+		hostPorts[s.hostPort()] = true
+	}
+
+	tOpts := servers[0].transportOpts()
+	tOpts.HostPorts = []string{servers[0].hostPort(), servers[1].hostPort()}
+
+	f := writeFile(t, "heatmap", "")
+	defer os.Remove(f)
+
+	m := benchmarkMethodForTest(t, fooMethod, transport.TChannel)
+	_, out := getOutput(t)
+	runBenchmark(out, Options{
+		BOpts: BenchmarkOptions{
+			MaxDuration:     50 * time.Millisecond,
+			Connections:     4,
+			Concurrency:     1,
+			HeatmapFeed:     f,
+			HeatmapInterval: 20 * time.Millisecond,
+		},
+		TOpts: tOpts,
+	}, m)
+
+	contents, err := ioutil.ReadFile(f)
+	require.NoError(t, err, "Failed to read heatmap feed")
+	tuples := decodeHeatmapTuples(t, contents)
+	require.NotEmpty(t, tuples, "Expected heatmap tuples")
+
+	seen := make(map[heatmapKey]map[int]bool)
+	for _, tuple := range tuples {
+		assert.Equal(t, fooMethod, tuple.Method, "Unexpected method")
+		assert.True(t, hostPorts[tuple.Peer], "Unexpected peer %v", tuple.Peer)
+		assert.True(t, tuple.RPS >= 0, "Unexpected negative RPS")
+
+		key := heatmapKey{tuple.Method, tuple.Peer}
+		if seen[key] == nil {
+			seen[key] = make(map[int]bool)
+		}
+		assert.False(t, seen[key][tuple.Window], "Duplicate tuple for %v in window %v", key, tuple.Window)
+		seen[key][tuple.Window] = true
+	}
+	assert.Len(t, seen, 2, "Expected tuples for both peers")
+}
@@ -0,0 +1,80 @@
+package limiter
+
+import "sync"
+
+// Inflight limits the number of requests that may be in-flight at once.
+// The limit can be changed while requests are in-flight.
+type Inflight struct {
+	mu      sync.Mutex
+	limit   int
+	current int
+	changed chan struct{}
+}
+
+// NewInflight returns an Inflight that allows at most limit requests
+// to be in-flight at once.
+func NewInflight(limit int) *Inflight {
+	return &Inflight{
+		limit:   limit,
+		changed: make(chan struct{}),
+	}
+}
+
+// Acquire blocks until a request can be started without exceeding the limit.
+// It returns false if cancel is closed before the request can be started.
+func (i *Inflight) Acquire(cancel <-chan struct{}) bool {
+	for {
+		i.mu.Lock()
+		if i.current < i.limit {
+			i.current++
+			i.mu.Unlock()
+			return true
+		}
+		changed := i.changed
+		i.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-cancel:
+			return false
+		}
+	}
+}
+
+// Release marks a previously acquired request as completed.
+func (i *Inflight) Release() {
+	i.mu.Lock()
+	i.current--
+	i.notify()
+	i.mu.Unlock()
+}
+
+// SetLimit updates the maximum number of in-flight requests. If the limit is
+// lowered, requests that are already in-flight are not affected.
+func (i *Inflight) SetLimit(limit int) {
+	i.mu.Lock()
+	i.limit = limit
+	i.notify()
+	i.mu.Unlock()
+}
+
+// Limit returns the current maximum number of in-flight requests.
+func (i *Inflight) Limit() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.limit
+}
+
+// Current returns the number of requests that are currently in-flight.
+func (i *Inflight) Current() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.current
+}
+
+// notify wakes up any callers blocked in Acquire. It must be called
+// with the lock held.
+func (i *Inflight) notify() {
+	close(i.changed)
+	i.changed = make(chan struct{})
+}
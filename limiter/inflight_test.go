@@ -0,0 +1,103 @@
+package limiter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+func TestInflightLimit(t *testing.T) {
+	inflight := NewInflight(2)
+	cancel := make(chan struct{})
+
+	assert.True(t, inflight.Acquire(cancel), "First acquire should succeed")
+	assert.True(t, inflight.Acquire(cancel), "Second acquire should succeed")
+	assert.Equal(t, 2, inflight.Current(), "Unexpected in-flight count")
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- inflight.Acquire(cancel)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("Acquire should block when the limit is reached")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	inflight.Release()
+	assert.True(t, <-acquired, "Acquire should succeed after a release")
+	assert.Equal(t, 2, inflight.Current(), "Unexpected in-flight count")
+}
+
+func TestInflightSetLimit(t *testing.T) {
+	inflight := NewInflight(1)
+	cancel := make(chan struct{})
+	assert.True(t, inflight.Acquire(cancel), "First acquire should succeed")
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- inflight.Acquire(cancel)
+	}()
+
+	inflight.SetLimit(2)
+	assert.True(t, <-acquired, "Acquire should succeed after raising the limit")
+	assert.Equal(t, 2, inflight.Limit(), "Unexpected limit")
+
+	inflight.SetLimit(1)
+	inflight.Release()
+	assert.Equal(t, 1, inflight.Current(), "Lowering the limit should not affect in-flight requests")
+}
+
+func TestInflightCancel(t *testing.T) {
+	inflight := NewInflight(0)
+	cancel := make(chan struct{})
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- inflight.Acquire(cancel)
+	}()
+
+	close(cancel)
+	assert.False(t, <-acquired, "Acquire should fail once cancelled")
+	assert.Equal(t, 0, inflight.Current(), "Cancelled acquire should not be in-flight")
+}
+
+func TestInflightParallel(t *testing.T) {
+	const limit = 3
+	inflight := NewInflight(limit)
+	cancel := make(chan struct{})
+
+	var (
+		wg      sync.WaitGroup
+		current atomic.Int32
+		maxSeen atomic.Int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if !assert.True(t, inflight.Acquire(cancel), "Acquire should succeed") {
+					return
+				}
+				n := current.Inc()
+				for {
+					m := maxSeen.Load()
+					if n <= m || maxSeen.CAS(m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				current.Dec()
+				inflight.Release()
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.True(t, maxSeen.Load() <= limit, "In-flight requests %v exceeded limit %v", maxSeen.Load(), limit)
+}
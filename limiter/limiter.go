@@ -53,6 +53,11 @@ func (r *Run) More() bool {
 	return r.requestsLeft.Dec() >= 0
 }
 
+// Done returns a channel that is closed when the run is stopped.
+func (r *Run) Done() <-chan struct{} {
+	return r.cancel
+}
+
 // Stop will ensure that all future calls to More return false.
 func (r *Run) Stop() {
 	if r.cancelled.Swap(true) {
@@ -43,7 +43,14 @@ import (
 	"github.com/uber/tchannel-go"
 )
 
-var errHealthAndMethod = errors.New("cannot specify method name and use --health")
+var (
+	errHealthAndMethod      = errors.New("cannot specify method name and use --health")
+	errTypeTaggedThriftOnly = errors.New("--type-tagged can only be used with Thrift")
+	errCheckMethodThrift    = errors.New("--thrift-check-method-name can only be used with Thrift")
+	errMaxStringThrift      = errors.New("--max-string-bytes can only be used with Thrift")
+	errNegativeMaxString    = errors.New("--max-string-bytes cannot be negative")
+	errJSONSafeRaw          = errors.New("--assert-json-safe cannot be used with raw encoding")
+)
 
 func findGroup(parser *flags.Parser, group string) *flags.Group {
 	if g := parser.Group.Find(group); g != nil {
@@ -252,9 +259,31 @@ func runWithOptions(opts Options, out output) {
 		out.Fatalf("Failed while loading headers input: %v\n", err)
 	}
 
-	serializer, err := NewSerializer(opts.ROpts)
-	if err != nil {
-		out.Fatalf("Failed while parsing input: %v\n", err)
+	if err := opts.BOpts.validate(opts.ROpts); err != nil {
+		out.Fatalf("Invalid benchmarking options: %v\n", err)
+	}
+
+	// A scenario uses the methods in the scenario file, so there may not be
+	// a method to create a serializer for.
+	var serializer encoding.Serializer
+	reqEncoding := detectEncoding(opts.ROpts)
+	if opts.BOpts.Scenario == "" {
+		serializer, err = NewSerializer(opts.ROpts)
+		if err != nil {
+			out.Fatalf("Failed while parsing input: %v\n", err)
+		}
+		reqEncoding = serializer.Encoding()
+	}
+
+	// base holds the fields that are shared by every request.
+	base := &transport.Request{
+		Headers:          headers,
+		TransportHeaders: opts.TOpts.TransportHeaders,
+		Timeout:          opts.ROpts.Timeout.Duration(),
+		Baggage:          opts.ROpts.Baggage,
+	}
+	if base.Timeout == 0 {
+		base.Timeout = time.Second
 	}
 
 	if opts.TOpts.CallerName != "" {
@@ -271,36 +300,56 @@ func runWithOptions(opts Options, out output) {
 	}
 
 	// transport abstracts the underlying wire protocol used to make the call.
-	transport, err := getTransport(opts.TOpts, serializer.Encoding(), tracer)
+	transport, err := getTransport(opts.TOpts, reqEncoding, tracer)
 	if err != nil {
 		out.Fatalf("Failed while parsing options: %v\n", err)
 	}
 
-	serializer = withTransportSerializer(transport.Protocol(), serializer, opts.ROpts)
+	// warnings is shared by every serializer so a repeated warning, such as a
+	// mismatched envelope method on each benchmark call, is only shown once.
+	warnings := newOnceWriter(out)
 
-	// req is the transport.Request that will be used to make a call.
-	req, err := serializer.Request(reqInput)
-	if err != nil {
-		out.Fatalf("Failed while parsing request input: %v\n", err)
-	}
+	var m benchmarkMethod
+	if opts.BOpts.Scenario != "" {
+		m.scenario, err = newScenarioMethod(opts.BOpts.Scenario, opts.ROpts, transport.Protocol(), base, warnings)
+		if err != nil {
+			out.Fatalf("Failed while loading scenario: %v\n", err)
+		}
+
+		// The first step is used for the initial request and warmup, rather
+		// than a method that is not part of the scenario.
+		m.serializer, m.req, err = m.scenario.firstRequest()
+		if err != nil {
+			out.Fatalf("Failed while loading scenario: %v\n", err)
+		}
+	} else {
+		serializer = withTransportSerializer(transport.Protocol(), serializer, opts.ROpts)
+		serializer, err = withResponseOptions(serializer, opts.ROpts, warnings)
+		if err != nil {
+			out.Fatalf("Failed while parsing options: %v\n", err)
+		}
+
+		// req is the transport.Request that will be used to make a call.
+		req, err := serializer.Request(reqInput)
+		if err != nil {
+			out.Fatalf("Failed while parsing request input: %v\n", err)
+		}
+
+		req.Headers = base.Headers
+		req.TransportHeaders = base.TransportHeaders
+		req.Timeout = base.Timeout
+		req.Baggage = base.Baggage
 
-	req.Headers = headers
-	req.TransportHeaders = opts.TOpts.TransportHeaders
-	req.Timeout = opts.ROpts.Timeout.Duration()
-	if req.Timeout == 0 {
-		req.Timeout = time.Second
+		m.serializer = serializer
+		m.req = req
 	}
-	req.Baggage = opts.ROpts.Baggage
 
 	// Only make the request if the user hasn't specified 0 warmup.
 	if !(opts.BOpts.enabled() && opts.BOpts.WarmupRequests == 0) {
-		makeInitialRequest(out, transport, serializer, req)
+		makeInitialRequest(out, transport, m.serializer, m.req)
 	}
 
-	runBenchmark(out, opts, benchmarkMethod{
-		serializer: serializer,
-		req:        req,
-	})
+	runBenchmark(out, opts, m)
 }
 
 type noEnveloper interface {
@@ -336,6 +385,77 @@ func withTransportSerializer(p transport.Protocol, s encoding.Serializer, rOpts
 	return s
 }
 
+type typeTagger interface {
+	WithTypeTags() encoding.Serializer
+}
+
+// The list of supported --thrift-check-method-name modes.
+const (
+	checkMethodError = "error"
+	checkMethodWarn  = "warn"
+)
+
+type envelopeMethodChecker interface {
+	WithEnvelopeMethodCheck(warnings io.Writer) (encoding.Serializer, error)
+}
+
+type stringLimiter interface {
+	WithMaxStringBytes(n int) encoding.Serializer
+}
+
+// withResponseOptions applies options that control how responses are
+// deserialized to the serializer. Warnings about responses are written to
+// warnings.
+func withResponseOptions(s encoding.Serializer, rOpts RequestOptions, warnings io.Writer) (encoding.Serializer, error) {
+	if rOpts.ThriftTypeTagged {
+		tagger, ok := s.(typeTagger)
+		if !ok {
+			return nil, errTypeTaggedThriftOnly
+		}
+		s = tagger.WithTypeTags()
+	}
+	if rOpts.ThriftCheckMethodName != "" {
+		checker, ok := s.(envelopeMethodChecker)
+		if !ok {
+			return nil, errCheckMethodThrift
+		}
+
+		var methodWarnings io.Writer
+		switch rOpts.ThriftCheckMethodName {
+		case checkMethodError:
+		case checkMethodWarn:
+			methodWarnings = warnings
+		default:
+			return nil, fmt.Errorf("unknown --thrift-check-method-name mode: %q", rOpts.ThriftCheckMethodName)
+		}
+
+		var err error
+		if s, err = checker.WithEnvelopeMethodCheck(methodWarnings); err != nil {
+			return nil, err
+		}
+	}
+	if rOpts.ThriftMaxStringBytes < 0 {
+		return nil, errNegativeMaxString
+	}
+	if rOpts.ThriftMaxStringBytes > 0 {
+		guard, ok := s.(stringLimiter)
+		if !ok {
+			return nil, errMaxStringThrift
+		}
+		s = guard.WithMaxStringBytes(rOpts.ThriftMaxStringBytes)
+	}
+
+	// The JSON safe check wraps the serializer, so it must be applied last.
+	if rOpts.AssertJSONSafe {
+		// Raw responses are always binary, so they would never pass.
+		if s.Encoding() == encoding.Raw {
+			return nil, errJSONSafeRaw
+		}
+		s = encoding.WithJSONSafeCheck(s)
+	}
+	return s, nil
+}
+
 // makeRequest makes a request using the given transport.
 func makeRequest(t transport.Transport, request *transport.Request) (*transport.Response, error) {
 	return makeRequestWithTracePriority(t, request, 0)
@@ -22,6 +22,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -752,6 +753,98 @@ func TestWithTransportSerializer(t *testing.T) {
 	}
 }
 
+func TestWithResponseOptions(t *testing.T) {
+	tests := []struct {
+		protocol transport.Protocol
+		rOpts    RequestOptions
+		wantErr  error
+	}{
+		{
+			rOpts: RequestOptions{ThriftFile: validThrift, MethodName: fooMethod},
+		},
+		{
+			rOpts: RequestOptions{ThriftFile: validThrift, MethodName: fooMethod, ThriftTypeTagged: true},
+		},
+		{
+			rOpts: RequestOptions{Encoding: encoding.JSON, MethodName: "foo"},
+		},
+		{
+			rOpts:   RequestOptions{Encoding: encoding.JSON, MethodName: "foo", ThriftTypeTagged: true},
+			wantErr: errTypeTaggedThriftOnly,
+		},
+		{
+			rOpts: RequestOptions{ThriftFile: validThrift, MethodName: fooMethod, ThriftCheckMethodName: "error"},
+		},
+		{
+			rOpts: RequestOptions{ThriftFile: validThrift, MethodName: fooMethod, ThriftCheckMethodName: "warn"},
+		},
+		{
+			rOpts:   RequestOptions{ThriftFile: validThrift, MethodName: fooMethod, ThriftCheckMethodName: "always"},
+			wantErr: errors.New(`unknown --thrift-check-method-name mode: "always"`),
+		},
+		{
+			protocol: transport.TChannel,
+			rOpts:    RequestOptions{ThriftFile: validThrift, MethodName: fooMethod, ThriftCheckMethodName: "error"},
+			wantErr:  encoding.ErrCheckMethodNoEnvelopes,
+		},
+		{
+			rOpts: RequestOptions{
+				ThriftFile:             validThrift,
+				MethodName:             fooMethod,
+				ThriftDisableEnvelopes: true,
+				ThriftCheckMethodName:  "warn",
+			},
+			wantErr: encoding.ErrCheckMethodNoEnvelopes,
+		},
+		{
+			rOpts:   RequestOptions{Encoding: encoding.Raw, MethodName: "foo", ThriftCheckMethodName: "error"},
+			wantErr: errCheckMethodThrift,
+		},
+		{
+			rOpts: RequestOptions{ThriftFile: validThrift, MethodName: fooMethod, ThriftMaxStringBytes: 10},
+		},
+		{
+			rOpts:   RequestOptions{Encoding: encoding.JSON, MethodName: "foo", ThriftMaxStringBytes: 10},
+			wantErr: errMaxStringThrift,
+		},
+		{
+			rOpts:   RequestOptions{ThriftFile: validThrift, MethodName: fooMethod, ThriftMaxStringBytes: -1},
+			wantErr: errNegativeMaxString,
+		},
+		{
+			rOpts: RequestOptions{Encoding: encoding.JSON, MethodName: "foo", AssertJSONSafe: true},
+		},
+		{
+			rOpts:   RequestOptions{Encoding: encoding.Raw, MethodName: "foo", AssertJSONSafe: true},
+			wantErr: errJSONSafeRaw,
+		},
+		{
+			rOpts: RequestOptions{
+				ThriftFile:       validThrift,
+				MethodName:       fooMethod,
+				ThriftTypeTagged: true,
+				AssertJSONSafe:   true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		serializer, err := NewSerializer(tt.rOpts)
+		require.NoError(t, err, "Failed to create serializer for %+v", tt.rOpts)
+		serializer = withTransportSerializer(tt.protocol, serializer, tt.rOpts)
+
+		got, err := withResponseOptions(serializer, tt.rOpts, &bytes.Buffer{})
+		if tt.wantErr != nil {
+			assert.Equal(t, tt.wantErr, err, "Unexpected error for %+v", tt.rOpts)
+			continue
+		}
+
+		if assert.NoError(t, err, "withResponseOptions failed for %+v", tt.rOpts) {
+			assert.Equal(t, serializer.Encoding(), got.Encoding(), "Encoding mismatch for %+v", tt.rOpts)
+		}
+	}
+}
+
 func cleanEnv(key, val string, wasSet bool) {
 	if wasSet {
 		os.Setenv(key, val)
@@ -840,3 +933,15 @@ func TestNoWarmupBenchmark(t *testing.T) {
 	assert.Contains(t, buf.String(), "Total errors: 100")
 	assert.Contains(t, buf.String(), "Error rate: 100")
 }
+
+func TestOnceWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := newOnceWriter(&buf)
+
+	for _, msg := range []string{"warning 1\n", "warning 2\n", "warning 1\n", "warning 2\n"} {
+		n, err := io.WriteString(w, msg)
+		assert.NoError(t, err, "Write failed")
+		assert.Equal(t, len(msg), n, "Write should report the full message as written")
+	}
+	assert.Equal(t, "warning 1\nwarning 2\n", buf.String(), "Each warning should be written once")
+}
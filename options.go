@@ -51,9 +51,15 @@ type RequestOptions struct {
 	Health      bool              `long:"health" description:"Hit the health endpoint, Meta::health"`
 	Timeout     timeMillisFlag    `long:"timeout" default:"1s" description:"The timeout for each request. E.g., 100ms, 0.5s, 1s. If no unit is specified, milliseconds are assumed."`
 
+	// AssertJSONSafe fails responses that cannot be serialized to JSON without loss.
+	AssertJSONSafe bool `long:"assert-json-safe" description:"Fails if the response contains fields that cannot be serialized to valid UTF-8 JSON, such as binary data or invalid UTF-8 strings. Cannot be used with raw encoding"`
+
 	// Thrift options
-	ThriftDisableEnvelopes bool `long:"disable-thrift-envelope" description:"Disables Thrift envelopes (disabled by default for TChannel)"`
-	ThriftMultiplexed      bool `long:"multiplexed-thrift" description:"Enables the Thrift TMultiplexedProtocol used by services that host multiple Thrift services on a single endpoint."`
+	ThriftDisableEnvelopes bool   `long:"disable-thrift-envelope" description:"Disables Thrift envelopes (disabled by default for TChannel)"`
+	ThriftMultiplexed      bool   `long:"multiplexed-thrift" description:"Enables the Thrift TMultiplexedProtocol used by services that host multiple Thrift services on a single endpoint."`
+	ThriftCheckMethodName  string `long:"thrift-check-method-name" optional:"yes" optional-value:"error" choice:"error" choice:"warn" description:"Checks that the method name in the Thrift response envelope matches the requested method. A mismatch fails the response (error, the default), or prints a warning and continues (warn), e.g. --thrift-check-method-name=warn"`
+	ThriftMaxStringBytes   int    `long:"max-string-bytes" description:"Fails if any string or binary value in the Thrift response is longer than this many bytes, before the response is decoded. This does not limit the size of the response body read by the transport. The default (0) is no limit."`
+	ThriftTypeTagged       bool   `long:"type-tagged" description:"Wraps scalar values in the Thrift response with their Thrift type, e.g. {\"type\": \"i64\", \"value\": 1}. Maps are written as a list of {\"key\": ..., \"value\": ...} items so keys are tagged too. Cannot be used with --scenario or --server-time-field"`
 
 	// These are aliases for tcurl compatibility.
 	Aliases struct {
@@ -92,6 +98,24 @@ type BenchmarkOptions struct {
 	WarmupRequests int `long:"warmup" description:"The number of requests to make to warmup each connection" default:"10"`
 	Concurrency    int `long:"concurrency" default:"1" description:"The number of concurrent calls per connection"`
 	RPS            int `long:"rps" default:"0" description:"Limit on the number of requests per second. The default (0) is no limit."`
+	MaxInflight    int `long:"max-inflight" default:"0" description:"Limit on the number of in-flight requests across all connections. The default (0) is no limit."`
+
+	// ConcurrencyProfile drives the in-flight limit over time.
+	ConcurrencyProfile string `long:"concurrency-profile" description:"Path of a JSON or YAML file containing a list of segments (duration, concurrency) that set the in-flight limit over time"`
+
+	// Format controls how the benchmark summary is printed.
+	Format string `long:"format" default:"plain" choice:"plain" choice:"table" description:"The format of the benchmark summary: plain, or table for aligned columns"`
+
+	// Scenario benchmarks a sequence of dependent calls per worker.
+	Scenario string `long:"scenario" description:"Path of a JSON or YAML file containing a list of steps (name, method, request) to run in order per benchmark request. Requests can reference fields of earlier responses, e.g. ${step.result.id}. The first step is used for the initial request and warmup, so --method and --request cannot be specified"`
+
+	// The server time field is subtracted from the latency to report network overhead.
+	ServerTimeField string        `long:"server-time-field" description:"Path of a field in the response containing the server processing time, e.g. result.timeMs. The latency minus the server time is reported as the network overhead. Cannot be used with --scenario or --type-tagged"`
+	ServerTimeUnit  time.Duration `long:"server-time-unit" default:"1ms" description:"The unit of the server time field"`
+
+	// The heatmap feed reports per-method and per-peer stats during the benchmark.
+	HeatmapFeed     string        `long:"heatmap-feed" description:"Path of a file, or stdout, to write per-method and per-peer throughput and p99 latency as JSON for each window"`
+	HeatmapInterval time.Duration `long:"heatmap-interval" default:"1s" description:"The length of each window in the heatmap feed"`
 
 	// Benchmark metrics can optionally be reported via statsd.
 	StatsdHostPort string `long:"statsd" description:"Optional host:port of a StatsD server to report metrics"`
@@ -25,6 +25,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"sync"
 )
 
 type output interface {
@@ -45,3 +46,27 @@ func (consoleOutput) Fatalf(format string, args ...interface{}) {
 func (consoleOutput) Printf(format string, args ...interface{}) {
 	fmt.Printf(format, args...)
 }
+
+// onceWriter passes each distinct write through to the underlying writer
+// once, so a warning repeated for every call in a benchmark is only reported
+// the first time. Each warning must be written with a single Write call.
+type onceWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	seen map[string]struct{}
+}
+
+func newOnceWriter(w io.Writer) *onceWriter {
+	return &onceWriter{w: w, seen: make(map[string]struct{})}
+}
+
+func (w *onceWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.seen[string(p)]; ok {
+		return len(p), nil
+	}
+	w.seen[string(p)] = struct{}{}
+	return w.w.Write(p)
+}
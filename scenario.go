@@ -0,0 +1,409 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yarpc/yab/encoding"
+	"github.com/yarpc/yab/statsd"
+	"github.com/yarpc/yab/transport"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	errScenarioEmpty      = errors.New("scenario must contain at least one step")
+	errScenarioTypeTagged = errors.New("do not specify --type-tagged and --scenario")
+	errScenarioRequest    = errors.New("do not specify --method or --request with --scenario, the scenario file specifies them")
+)
+
+// scenarioRefRegex matches references to earlier responses in a step's
+// request, e.g. ${create.result.id}.
+var scenarioRefRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// scenarioStep is a single step of a scenario as specified in the scenario file.
+type scenarioStep struct {
+	Name    string      `yaml:"name"`
+	Method  string      `yaml:"method"`
+	Request interface{} `yaml:"request"`
+}
+
+// scenarioMethod runs an ordered list of dependent calls, where a step's
+// request can reference fields from the responses of earlier steps.
+type scenarioMethod struct {
+	steps []scenarioStepMethod
+}
+
+type scenarioStepMethod struct {
+	name       string
+	method     string
+	serializer encoding.Serializer
+	template   interface{}
+	base       *transport.Request
+}
+
+func parseScenarioFile(filename string) ([]scenarioStep, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scenario: %v", err)
+	}
+
+	var steps []scenarioStep
+	if err := yaml.Unmarshal(contents, &steps); err != nil {
+		return nil, fmt.Errorf("scenario should be a JSON or YAML list of steps: %v", err)
+	}
+
+	return steps, validateScenario(steps)
+}
+
+func validateScenario(steps []scenarioStep) error {
+	if len(steps) == 0 {
+		return errScenarioEmpty
+	}
+
+	// Steps can only reference the responses of steps that run before them.
+	seen := make(map[string]bool)
+	for i, step := range steps {
+		if step.Method == "" {
+			return fmt.Errorf("scenario step %v is missing a method", i)
+		}
+		if step.Name == "" {
+			return fmt.Errorf("scenario step %v is missing a name", i)
+		}
+		if seen[step.Name] {
+			return fmt.Errorf("scenario step name %q is used more than once", step.Name)
+		}
+
+		for _, ref := range templateRefs(step.Request) {
+			if stepName := strings.SplitN(ref, ".", 2)[0]; !seen[stepName] {
+				return fmt.Errorf("scenario step %q references %q before it has run", step.Name, stepName)
+			}
+		}
+		seen[step.Name] = true
+	}
+
+	return nil
+}
+
+// newScenarioMethod creates a scenarioMethod from the given scenario file.
+// Each step uses the encoding and response options from rOpts with the
+// step's method, and the headers and timeout from the base request.
+// Warnings about step responses are written to warnings.
+func newScenarioMethod(filename string, rOpts RequestOptions, p transport.Protocol, base *transport.Request, warnings io.Writer) (*scenarioMethod, error) {
+	steps, err := parseScenarioFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	scenario := &scenarioMethod{}
+	for _, step := range steps {
+		stepOpts := rOpts
+		stepOpts.Health = false
+		stepOpts.MethodName = step.Method
+
+		serializer, err := NewSerializer(stepOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create serializer for step %q: %v", step.Name, err)
+		}
+
+		serializer, err = withResponseOptions(withTransportSerializer(p, serializer, stepOpts), stepOpts, warnings)
+		if err != nil {
+			return nil, fmt.Errorf("invalid options for step %q: %v", step.Name, err)
+		}
+
+		scenario.steps = append(scenario.steps, scenarioStepMethod{
+			name:       step.Name,
+			method:     step.Method,
+			serializer: serializer,
+			template:   step.Request,
+			base:       base,
+		})
+	}
+
+	return scenario, nil
+}
+
+// firstRequest returns the serializer and request for the first step of the
+// scenario, which is used for the initial request and to warm up transports.
+// The first step cannot reference other steps, so it never changes.
+func (s *scenarioMethod) firstRequest() (encoding.Serializer, *transport.Request, error) {
+	first := s.steps[0]
+	req, err := first.request(nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request for step %q: %v", first.name, err)
+	}
+	return first.serializer, req, nil
+}
+
+// newStepStates returns a benchmarkState for each step in the scenario.
+func (s *scenarioMethod) newStepStates() []*benchmarkState {
+	states := make([]*benchmarkState, len(s.steps))
+	for i := range states {
+		states[i] = newBenchmarkState(statsd.Noop)
+	}
+	return states
+}
+
+// call runs all the steps of the scenario in order, recording the result
+// of each step in stepStates, and in rec if it is non-nil. It returns the
+// latency of the whole scenario.
+func (s *scenarioMethod) call(t transport.Transport, stepStates []*benchmarkState, rec heatmapRecorder) (time.Duration, error) {
+	start := time.Now()
+	responses := make(map[string]interface{}, len(s.steps))
+	for i, step := range s.steps {
+		latency, res, err := step.call(t, responses)
+		if rec != nil {
+			rec(step.method, latency, err)
+		}
+		if err != nil {
+			err = fmt.Errorf("step %q failed: %v", step.name, err)
+			stepStates[i].recordError(err)
+			return 0, err
+		}
+
+		stepStates[i].recordLatency(latency)
+		responses[step.name] = res
+	}
+
+	return time.Since(start), nil
+}
+
+func (s *scenarioMethod) printStepLatencies(out output, stepStates []*benchmarkState) {
+	out.Printf("Step latencies:\n")
+	for i, step := range s.steps {
+		state := stepStates[i]
+		out.Printf("  %v: %v requests, %v errors\n", step.name, state.totalRequests, state.totalErrors)
+		state.printQuantiles(out, "    ")
+	}
+}
+
+// stepRows returns the summary rows for each step of the scenario.
+func (s *scenarioMethod) stepRows(stepStates []*benchmarkState) []summaryRow {
+	var rows []summaryRow
+	for i, step := range s.steps {
+		state := stepStates[i]
+		sort.Sort(byDuration(state.latencies))
+
+		prefix := "Step " + step.name
+		rows = append(rows,
+			summaryRow{prefix + " requests", strconv.Itoa(state.totalRequests), "requests"},
+			summaryRow{prefix + " errors", strconv.Itoa(state.totalErrors), "errors"},
+		)
+		rows = append(rows, state.latencyRows(prefix)...)
+	}
+	return rows
+}
+
+// request renders the step's request using the responses of earlier steps.
+func (s scenarioStepMethod) request(responses map[string]interface{}) (*transport.Request, error) {
+	var body []byte
+	if s.template != nil {
+		rendered, err := renderTemplate(s.template, responses)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err = json.Marshal(rendered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %v", err)
+		}
+	}
+
+	req, err := s.serializer.Request(body)
+	if err != nil {
+		return nil, err
+	}
+	req.Headers = s.base.Headers
+	req.TransportHeaders = s.base.TransportHeaders
+	req.Timeout = s.base.Timeout
+	req.Baggage = s.base.Baggage
+	return req, nil
+}
+
+func (s scenarioStepMethod) call(t transport.Transport, responses map[string]interface{}) (time.Duration, interface{}, error) {
+	req, err := s.request(responses)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	start := time.Now()
+	res, err := makeRequest(t, req)
+	latency := time.Since(start)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := s.serializer.CheckSuccess(res); err != nil {
+		return 0, nil, err
+	}
+
+	decoded, err := s.serializer.Response(res)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return latency, decoded, nil
+}
+
+// renderTemplate replaces references in v with the referenced response
+// fields, and converts YAML maps to maps that can be marshalled to JSON.
+func renderTemplate(v interface{}, responses map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return renderString(v, responses)
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, mv := range v {
+			rendered, err := renderTemplate(mv, responses)
+			if err != nil {
+				return nil, err
+			}
+			result[fmt.Sprint(k)] = rendered
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, lv := range v {
+			rendered, err := renderTemplate(lv, responses)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = rendered
+		}
+		return result, nil
+	}
+
+	return v, nil
+}
+
+// renderString resolves references in s. If s is a single reference, the
+// referenced value is returned as-is so that its type is preserved. References
+// within a larger string must refer to scalar values.
+func renderString(s string, responses map[string]interface{}) (interface{}, error) {
+	matches := scenarioRefRegex.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	if m := matches[0]; len(matches) == 1 && m[0] == 0 && m[1] == len(s) {
+		return resolveRef(s[m[2]:m[3]], responses)
+	}
+
+	var resolveErr error
+	rendered := scenarioRefRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		path := scenarioRefRegex.FindStringSubmatch(ref)[1]
+		v, err := resolveRef(path, responses)
+		if err != nil {
+			resolveErr = err
+			return ""
+		}
+
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			resolveErr = fmt.Errorf("cannot use %q within a string: it is a map or list, "+
+				"reference it as the whole value instead", path)
+			return ""
+		}
+		return fmt.Sprint(v)
+	})
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+	return rendered, nil
+}
+
+// resolveRef resolves a path such as create.result.items.0 against the
+// responses of earlier steps.
+func resolveRef(path string, responses map[string]interface{}) (interface{}, error) {
+	parts := strings.Split(path, ".")
+	cur, ok := responses[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("could not resolve %q: unknown step %q", path, parts[0])
+	}
+
+	v, missing, ok := lookupPath(cur, parts[1:])
+	if !ok {
+		return nil, fmt.Errorf("could not resolve %q: no field %q", path, missing)
+	}
+	return v, nil
+}
+
+// lookupPath walks the maps and lists in v using parts. If a part cannot be
+// found, it is returned along with ok set to false.
+func lookupPath(v interface{}, parts []string) (_ interface{}, missing string, ok bool) {
+	for _, part := range parts {
+		switch cur := v.(type) {
+		case map[string]interface{}:
+			v, ok = cur[part]
+		case []interface{}:
+			var idx int
+			idx, ok = parseIndex(part, len(cur))
+			if ok {
+				v = cur[idx]
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, part, false
+		}
+	}
+
+	return v, "", true
+}
+
+func parseIndex(s string, length int) (int, bool) {
+	idx, err := strconv.Atoi(s)
+	if err != nil || idx < 0 || idx >= length {
+		return 0, false
+	}
+	return idx, true
+}
+
+// templateRefs returns all the references used within v.
+func templateRefs(v interface{}) []string {
+	var refs []string
+	switch v := v.(type) {
+	case string:
+		for _, m := range scenarioRefRegex.FindAllStringSubmatch(v, -1) {
+			refs = append(refs, m[1])
+		}
+	case map[interface{}]interface{}:
+		for _, mv := range v {
+			refs = append(refs, templateRefs(mv)...)
+		}
+	case []interface{}:
+		for _, lv := range v {
+			refs = append(refs, templateRefs(lv)...)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,349 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/yarpc/yab/encoding"
+	"github.com/yarpc/yab/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go/raw"
+	"go.uber.org/thriftrw/protocol"
+	"go.uber.org/thriftrw/wire"
+	"golang.org/x/net/context"
+)
+
+const twoStepScenario = `
+- name: create
+  method: create
+  request:
+    name: foo
+- name: fetch
+  method: fetch
+  request:
+    id: ${create.id}
+    label: item-${create.id}
+    tags: ["${create.tags.1}"]
+`
+
+func TestParseScenarioFile(t *testing.T) {
+	tests := []struct {
+		contents string
+		wantErr  string
+	}{
+		{
+			contents: twoStepScenario,
+		},
+		{
+			contents: "[]",
+			wantErr:  errScenarioEmpty.Error(),
+		},
+		{
+			contents: "- name: a\n",
+			wantErr:  "scenario step 0 is missing a method",
+		},
+		{
+			contents: "- method: a\n",
+			wantErr:  "scenario step 0 is missing a name",
+		},
+		{
+			contents: "- {name: a, method: a}\n- {name: a, method: b}\n",
+			wantErr:  `scenario step name "a" is used more than once`,
+		},
+		{
+			contents: "- {name: a, method: a, request: {id: '${b.id}'}}\n- {name: b, method: b}\n",
+			wantErr:  `scenario step "a" references "b" before it has run`,
+		},
+		{
+			contents: "not a list",
+			wantErr:  "scenario should be a JSON or YAML list of steps",
+		},
+	}
+
+	for _, tt := range tests {
+		f := writeFile(t, "scenario", tt.contents)
+		steps, err := parseScenarioFile(f)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "Expected error for %q", tt.contents) {
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error for %q", tt.contents)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, "Failed to parse %q", tt.contents) {
+			assert.Len(t, steps, 2, "Unexpected number of steps")
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	responses := map[string]interface{}{
+		"create": map[string]interface{}{
+			"id":    int64(42),
+			"items": []interface{}{"a", "b"},
+		},
+	}
+
+	tests := []struct {
+		template interface{}
+		want     interface{}
+		wantErr  string
+	}{
+		{
+			template: "plain",
+			want:     "plain",
+		},
+		{
+			template: "${create.id}",
+			want:     int64(42),
+		},
+		{
+			template: "id-${create.id}-${create.items.1}",
+			want:     "id-42-b",
+		},
+		{
+			template: map[interface{}]interface{}{
+				"id":   "${create.id}",
+				"list": []interface{}{"${create.items.0}", 1},
+			},
+			want: map[string]interface{}{
+				"id":   int64(42),
+				"list": []interface{}{"a", 1},
+			},
+		},
+		{
+			template: "${fetch.id}",
+			wantErr:  `unknown step "fetch"`,
+		},
+		{
+			template: "${create.missing}",
+			wantErr:  `no field "missing"`,
+		},
+		{
+			template: "prefix-${create.items.5}",
+			wantErr:  `no field "5"`,
+		},
+		{
+			template: "items: ${create.items}",
+			wantErr:  `cannot use "create.items" within a string`,
+		},
+		{
+			template: "${create.items}",
+			want:     []interface{}{"a", "b"},
+		},
+		{
+			template: "${create.id.nested}",
+			wantErr:  `no field "nested"`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := renderTemplate(tt.template, responses)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "Expected error for %v", tt.template) {
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error for %v", tt.template)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, "Failed to render %v", tt.template) {
+			assert.Equal(t, tt.want, got, "Unexpected result for %v", tt.template)
+		}
+	}
+}
+
+func newScenarioServer(t *testing.T) (*server, chan []byte) {
+	fetched := make(chan []byte, 100)
+	s := newServer(t)
+	s.register("create", methods.customArg3([]byte(`{"id": 42, "tags": ["x", "y"]}`)))
+	s.register("fetch", func(ctx context.Context, args *raw.Args) (*raw.Res, error) {
+		fetched <- args.Arg3
+		return &raw.Res{Arg2: args.Arg2, Arg3: []byte(`{"ok": true}`)}, nil
+	})
+	return s, fetched
+}
+
+func TestScenarioCall(t *testing.T) {
+	s, fetched := newScenarioServer(t)
+	defer s.shutdown()
+
+	rOpts := RequestOptions{Encoding: encoding.JSON}
+	scenario, err := newScenarioMethod(writeFile(t, "scenario", twoStepScenario), rOpts,
+		transport.TChannel, &transport.Request{Timeout: time.Second}, nil /* warnings */)
+	require.NoError(t, err, "Failed to create scenario")
+
+	m := benchmarkMethod{serializer: encoding.NewJSON("create"), req: &transport.Request{Method: "create", Timeout: time.Second}}
+	tp, err := m.WarmTransport(s.transportOpts(), 0 /* warmupRequests */)
+	require.NoError(t, err, "Failed to create transport")
+
+	stepStates := scenario.newStepStates()
+	latency, err := scenario.call(tp, stepStates, nil)
+	require.NoError(t, err, "Scenario call failed")
+	assert.True(t, latency > 0, "Expected positive scenario latency")
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(<-fetched, &got), "Failed to unmarshal fetch request")
+	assert.Equal(t, map[string]interface{}{
+		"id":    float64(42),
+		"label": "item-42",
+		"tags":  []interface{}{"y"},
+	}, got, "Second step should use the first step's response")
+
+	for i, state := range stepStates {
+		assert.Equal(t, 1, state.totalSuccess, "Unexpected successes for step %v", i)
+		assert.Len(t, state.latencies, 1, "Unexpected latencies for step %v", i)
+	}
+}
+
+func TestScenarioCallStepError(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("create", methods.customArg3([]byte(`{"name": "foo"}`)))
+
+	rOpts := RequestOptions{Encoding: encoding.JSON}
+	scenario, err := newScenarioMethod(writeFile(t, "scenario", twoStepScenario), rOpts,
+		transport.TChannel, &transport.Request{Timeout: time.Second}, nil /* warnings */)
+	require.NoError(t, err, "Failed to create scenario")
+
+	m := benchmarkMethod{serializer: encoding.NewJSON("create"), req: &transport.Request{Method: "create", Timeout: time.Second}}
+	tp, err := m.WarmTransport(s.transportOpts(), 0 /* warmupRequests */)
+	require.NoError(t, err, "Failed to create transport")
+
+	stepStates := scenario.newStepStates()
+	_, err = scenario.call(tp, stepStates, nil)
+	if assert.Error(t, err, "Scenario should fail when the reference is missing") {
+		assert.Contains(t, err.Error(), `step "fetch" failed`, "Unexpected error")
+		assert.Contains(t, err.Error(), `no field "id"`, "Unexpected error")
+	}
+	assert.Equal(t, 1, stepStates[0].totalSuccess, "First step should succeed")
+	assert.Equal(t, 1, stepStates[1].totalErrors, "Second step should fail")
+}
+
+func TestBenchmarkScenario(t *testing.T) {
+	s, fetched := newScenarioServer(t)
+	defer s.shutdown()
+
+	rOpts := RequestOptions{Encoding: encoding.JSON, MethodName: "create"}
+	serializer, err := NewSerializer(rOpts)
+	require.NoError(t, err, "Failed to create serializer")
+	req, err := serializer.Request(nil)
+	require.NoError(t, err, "Failed to create request")
+	req.Timeout = time.Second
+
+	scenario, err := newScenarioMethod(writeFile(t, "scenario", twoStepScenario), rOpts,
+		transport.TChannel, req, nil /* warnings */)
+	require.NoError(t, err, "Failed to create scenario")
+
+	buf, out := getOutput(t)
+	runBenchmark(out, Options{
+		BOpts: BenchmarkOptions{
+			MaxRequests: 10,
+			Connections: 2,
+			Concurrency: 1,
+		},
+		TOpts: s.transportOpts(),
+	}, benchmarkMethod{serializer: serializer, req: req, scenario: scenario})
+
+	bufStr := buf.String()
+	assert.Contains(t, bufStr, "Scenario steps:  2")
+	assert.Contains(t, bufStr, "Step latencies:")
+	assert.Contains(t, bufStr, "create: 10 requests, 0 errors")
+	assert.Contains(t, bufStr, "fetch: 10 requests, 0 errors")
+	assert.Contains(t, bufStr, "Total requests:    10")
+	assert.NotContains(t, bufStr, "Errors")
+	assert.Len(t, fetched, 10, "Unexpected number of fetch calls")
+}
+
+func TestScenarioStepResponseOptions(t *testing.T) {
+	var res bytes.Buffer
+	result := wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+		{ID: 0, Value: wire.NewValueString("longer than the limit")},
+	}})
+	require.NoError(t, protocol.Binary.Encode(result, &res), "Failed to encode response")
+
+	s := newServer(t)
+	defer s.shutdown()
+	s.register("Store::get", methods.customArg3(res.Bytes()))
+
+	scenarioFile := writeFile(t, "scenario", "- name: get\n  method: Store::get\n")
+	rOpts := RequestOptions{
+		ThriftFile:           writeFile(t, "thrift", "service Store {\n  string get()\n}\n"),
+		ThriftMaxStringBytes: 8,
+	}
+	scenario, err := newScenarioMethod(scenarioFile, rOpts,
+		transport.TChannel, &transport.Request{Timeout: time.Second}, nil /* warnings */)
+	require.NoError(t, err, "Failed to create scenario")
+
+	serializer, req, err := scenario.firstRequest()
+	require.NoError(t, err, "Failed to create first request")
+	m := benchmarkMethod{serializer: serializer, req: req}
+	tp, err := m.WarmTransport(s.transportOpts(), 0 /* warmupRequests */)
+	require.NoError(t, err, "Failed to create transport")
+
+	stepStates := scenario.newStepStates()
+	_, err = scenario.call(tp, stepStates, nil)
+	if assert.Error(t, err, "Scenario should fail when a step's response exceeds --max-string-bytes") {
+		assert.Contains(t, err.Error(), `step "get" failed`, "Unexpected error")
+		assert.Contains(t, err.Error(), "exceeds the limit of 8 bytes", "Unexpected error")
+	}
+	assert.Equal(t, 1, stepStates[0].totalErrors, "Step should record the error")
+
+	// Response options that the step's encoding does not support are rejected.
+	_, err = newScenarioMethod(scenarioFile, RequestOptions{Encoding: encoding.JSON, ThriftMaxStringBytes: 8},
+		transport.TChannel, &transport.Request{Timeout: time.Second}, nil /* warnings */)
+	if assert.Error(t, err, "Expected error for Thrift-only option with JSON") {
+		assert.Contains(t, err.Error(), errMaxStringThrift.Error(), "Unexpected error")
+	}
+}
+
+func TestRunWithOptionsScenario(t *testing.T) {
+	s, fetched := newScenarioServer(t)
+	defer s.shutdown()
+
+	tOpts := s.transportOpts()
+	tOpts.CallerName = ""
+	buf, out := getOutput(t)
+	runWithOptions(Options{
+		// No --method is needed, the first step is used for the initial request.
+		ROpts: RequestOptions{Encoding: encoding.JSON},
+		TOpts: tOpts,
+		BOpts: BenchmarkOptions{
+			Scenario:       writeFile(t, "scenario", twoStepScenario),
+			MaxRequests:    5,
+			WarmupRequests: 1,
+			Connections:    1,
+			Concurrency:    1,
+		},
+	}, out)
+
+	bufStr := buf.String()
+	assert.Contains(t, bufStr, `"id": 42`, "Initial request should use the first step")
+	assert.Contains(t, bufStr, "Scenario steps:  2")
+	assert.Contains(t, bufStr, "create: 5 requests, 0 errors")
+	assert.Contains(t, bufStr, "fetch: 5 requests, 0 errors")
+	assert.Len(t, fetched, 5, "Only the benchmark should call the second step")
+}
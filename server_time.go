@@ -0,0 +1,107 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yarpc/yab/transport"
+)
+
+var (
+	errServerTimeScenario   = errors.New("do not specify --server-time-field and --scenario")
+	errServerTimeTypeTagged = errors.New("do not specify --server-time-field and --type-tagged")
+	errServerTimeUnit       = errors.New("server time unit must be positive")
+)
+
+// serverTimeField extracts the server-reported processing time from a
+// decoded response, so it can be subtracted from the measured latency.
+type serverTimeField struct {
+	path string
+	unit time.Duration
+}
+
+func newServerTimeField(path string, unit time.Duration) *serverTimeField {
+	return &serverTimeField{path: path, unit: unit}
+}
+
+// extract returns the server time in the given decoded response.
+func (f *serverTimeField) extract(response interface{}) (time.Duration, error) {
+	v, missing, ok := lookupPath(response, strings.Split(f.path, "."))
+	if !ok {
+		return 0, fmt.Errorf("server time field %q not found in response: no field %q", f.path, missing)
+	}
+
+	n, ok := toFloat64(v)
+	if !ok {
+		return 0, fmt.Errorf("server time field %q is not a number: %v", f.path, v)
+	}
+
+	return time.Duration(n * float64(f.unit)), nil
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// callWithServerTime makes a call and returns the measured latency along
+// with the server time reported in the response.
+func (m benchmarkMethod) callWithServerTime(t transport.Transport) (latency, serverTime time.Duration, err error) {
+	start := time.Now()
+	res, err := makeRequest(t, m.req)
+	latency = time.Since(start)
+	if err != nil {
+		return latency, 0, err
+	}
+
+	if err := m.serializer.CheckSuccess(res); err != nil {
+		return latency, 0, err
+	}
+
+	decoded, err := m.serializer.Response(res)
+	if err != nil {
+		return latency, 0, err
+	}
+
+	serverTime, err = m.serverTime.extract(decoded)
+	return latency, serverTime, err
+}
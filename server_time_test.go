@@ -0,0 +1,154 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/yarpc/yab/encoding"
+	"github.com/yarpc/yab/limiter"
+	"github.com/yarpc/yab/statsd"
+	"github.com/yarpc/yab/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTimeFieldExtract(t *testing.T) {
+	tests := []struct {
+		path     string
+		unit     time.Duration
+		response interface{}
+		want     time.Duration
+		wantErr  string
+	}{
+		{
+			path:     "result.timeMs",
+			unit:     time.Millisecond,
+			response: map[string]interface{}{"result": map[string]interface{}{"timeMs": int64(5)}},
+			want:     5 * time.Millisecond,
+		},
+		{
+			path:     "timeUs",
+			unit:     time.Microsecond,
+			response: map[string]interface{}{"timeUs": json.Number("1500")},
+			want:     1500 * time.Microsecond,
+		},
+		{
+			path:     "times.1",
+			unit:     time.Millisecond,
+			response: map[string]interface{}{"times": []interface{}{int32(1), 2.5}},
+			want:     2500 * time.Microsecond,
+		},
+		{
+			path:     "result.missing",
+			unit:     time.Millisecond,
+			response: map[string]interface{}{"result": map[string]interface{}{}},
+			wantErr:  `server time field "result.missing" not found in response: no field "missing"`,
+		},
+		{
+			path:     "result",
+			unit:     time.Millisecond,
+			response: map[string]interface{}{"result": "fast"},
+			wantErr:  `server time field "result" is not a number: fast`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := newServerTimeField(tt.path, tt.unit).extract(tt.response)
+		if tt.wantErr != "" {
+			if assert.Error(t, err, "Expected error for %v", tt.path) {
+				assert.Contains(t, err.Error(), tt.wantErr, "Unexpected error for %v", tt.path)
+			}
+			continue
+		}
+
+		if assert.NoError(t, err, "Failed to extract %v", tt.path) {
+			assert.Equal(t, tt.want, got, "Unexpected server time for %v", tt.path)
+		}
+	}
+}
+
+func serverTimeMethodForTest(t *testing.T, s *server) (benchmarkMethod, transport.Transport) {
+	s.register("timed", methods.customArg3([]byte(`{"serverTime": 5}`)))
+
+	m := benchmarkMethod{
+		serializer: encoding.NewJSON("timed"),
+		req:        &transport.Request{Method: "timed", Timeout: time.Second},
+		serverTime: newServerTimeField("serverTime", time.Millisecond),
+	}
+	tp, err := m.WarmTransport(s.transportOpts(), 0 /* warmupRequests */)
+	require.NoError(t, err, "Failed to create transport")
+	return m, tp
+}
+
+func TestRunWorkerServerTime(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	m, tp := serverTimeMethodForTest(t, s)
+
+	state := newBenchmarkState(statsd.Noop)
+	runWorker(tp, m, state, limiter.New(5 /* maxRequests */, 0 /* rps */, 0 /* maxDuration */), nil, nil)
+
+	require.Equal(t, 5, state.totalSuccess, "Unexpected number of successful requests")
+	require.Len(t, state.overheads, len(state.latencies), "Expected an overhead per latency")
+	for i, latency := range state.latencies {
+		assert.Equal(t, latency-5*time.Millisecond, state.overheads[i],
+			"Overhead should be the latency minus the server time")
+	}
+}
+
+func TestRunWorkerServerTimeMissing(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	m, tp := serverTimeMethodForTest(t, s)
+	m.serverTime = newServerTimeField("missing", time.Millisecond)
+
+	state := newBenchmarkState(statsd.Noop)
+	runWorker(tp, m, state, limiter.New(2 /* maxRequests */, 0 /* rps */, 0 /* maxDuration */), nil, nil)
+
+	assert.Equal(t, 2, state.totalErrors, "Missing server time should be an error")
+	assert.Empty(t, state.overheads, "No overheads should be recorded for errors")
+}
+
+func TestBenchmarkServerTime(t *testing.T) {
+	s := newServer(t)
+	defer s.shutdown()
+	m, _ := serverTimeMethodForTest(t, s)
+	m.serverTime = nil
+
+	buf, out := getOutput(t)
+	runBenchmark(out, Options{
+		BOpts: BenchmarkOptions{
+			MaxRequests:     10,
+			Connections:     2,
+			Concurrency:     1,
+			ServerTimeField: "serverTime",
+			ServerTimeUnit:  time.Millisecond,
+		},
+		TOpts: s.transportOpts(),
+	}, m)
+
+	assert.Contains(t, buf.String(), "Network overhead (latency - server time):")
+	assert.NotContains(t, buf.String(), "Errors")
+}
@@ -21,8 +21,10 @@
 package thrift
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"go.uber.org/thriftrw/wire"
 )
@@ -86,20 +88,22 @@ func (e specValueMismatch) Error() string {
 
 type specListItemMismatch struct {
 	index      int
+	value      string
 	underlying error
 }
 
 func (e specListItemMismatch) Error() string {
-	return fmt.Sprintf("item %v failed: %v", e.index, e.underlying)
+	return fmt.Sprintf("item %v failed: %v (got value %v)", e.index, e.underlying, e.value)
 }
 
 type specMapItemMismatch struct {
 	specType   string
+	value      string
 	underlying error
 }
 
 func (e specMapItemMismatch) Error() string {
-	return fmt.Sprintf("%v failed: %v", e.specType, e.underlying)
+	return fmt.Sprintf("%v failed: %v (got value %v)", e.specType, e.underlying, e.value)
 }
 
 type specStructFieldMismatch struct {
@@ -111,6 +115,106 @@ func (e specStructFieldMismatch) Error() string {
 	return fmt.Sprintf("%q failed: %v", e.fieldName, e.underlying)
 }
 
+// maxValuePreview is the maximum number of characters of a value that are
+// included in an error message.
+const maxValuePreview = 64
+
+// previewWireValue renders a wire.Value for use in an error message,
+// truncating the result if it is too long.
+func previewWireValue(w wire.Value) string {
+	var buf bytes.Buffer
+	writeWireValue(&buf, w)
+
+	s := buf.String()
+	if len(s) <= maxValuePreview {
+		return s
+	}
+
+	// Cut on a rune boundary so the preview is always valid UTF-8.
+	cut := maxValuePreview
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
+
+// writeWireValue writes a rendering of w to buf. Containers stop being
+// rendered once buf exceeds maxValuePreview, so large values are not
+// rendered in full only to be truncated.
+func writeWireValue(buf *bytes.Buffer, w wire.Value) {
+	switch w.Type() {
+	case wire.TBool:
+		fmt.Fprint(buf, w.GetBool())
+	case wire.TI8:
+		fmt.Fprint(buf, w.GetI8())
+	case wire.TI16:
+		fmt.Fprint(buf, w.GetI16())
+	case wire.TI32:
+		fmt.Fprint(buf, w.GetI32())
+	case wire.TI64:
+		fmt.Fprint(buf, w.GetI64())
+	case wire.TDouble:
+		fmt.Fprint(buf, w.GetDouble())
+	case wire.TBinary:
+		// Only quote as much of the value as can appear in the preview.
+		bs := w.GetBinary()
+		if len(bs) > maxValuePreview {
+			bs = bs[:maxValuePreview+1]
+		}
+		fmt.Fprintf(buf, "%q", bs)
+	case wire.TList:
+		writeWireValues(buf, wire.ValueListToSlice(w.GetList()))
+	case wire.TSet:
+		writeWireValues(buf, wire.ValueListToSlice(w.GetSet()))
+	case wire.TMap:
+		buf.WriteString("{")
+		for i, item := range wire.MapItemListToSlice(w.GetMap()) {
+			if !writeSeparator(buf, i) {
+				break
+			}
+			writeWireValue(buf, item.Key)
+			buf.WriteString(": ")
+			writeWireValue(buf, item.Value)
+		}
+		buf.WriteString("}")
+	case wire.TStruct:
+		buf.WriteString("{")
+		for i, f := range w.GetStruct().Fields {
+			if !writeSeparator(buf, i) {
+				break
+			}
+			fmt.Fprintf(buf, "%v: ", f.ID)
+			writeWireValue(buf, f.Value)
+		}
+		buf.WriteString("}")
+	default:
+		buf.WriteString(w.String())
+	}
+}
+
+func writeWireValues(buf *bytes.Buffer, values []wire.Value) {
+	buf.WriteString("[")
+	for i, v := range values {
+		if !writeSeparator(buf, i) {
+			break
+		}
+		writeWireValue(buf, v)
+	}
+	buf.WriteString("]")
+}
+
+// writeSeparator writes the separator before the i'th item of a container.
+// It returns false if the preview is already long enough to be truncated.
+func writeSeparator(buf *bytes.Buffer, i int) bool {
+	if buf.Len() > maxValuePreview {
+		return false
+	}
+	if i > 0 {
+		buf.WriteString(", ")
+	}
+	return true
+}
+
 // messageList formats a message and a list for an error message.
 func messageList(message string, list []string) string {
 	if len(list) == 0 {
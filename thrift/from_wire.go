@@ -79,7 +79,7 @@ func valueFromWireList(spec *compile.ListSpec, w wire.ValueList) ([]interface{},
 		var err error
 		result[i], err = valueFromWire(spec.ValueSpec, v)
 		if err != nil {
-			return nil, specListItemMismatch{i, err}
+			return nil, specListItemMismatch{i, previewWireValue(v), err}
 		}
 	}
 	return result, nil
@@ -98,12 +98,12 @@ func valueFromWireMap(spec *compile.MapSpec, w wire.MapItemList) (map[string]int
 	for _, v := range values {
 		key, err := valueFromWire(spec.KeySpec, v.Key)
 		if err != nil {
-			return nil, specMapItemMismatch{"key", err}
+			return nil, specMapItemMismatch{"key", previewWireValue(v.Key), err}
 		}
 
 		value, err := valueFromWire(spec.ValueSpec, v.Value)
 		if err != nil {
-			return nil, specMapItemMismatch{"value", err}
+			return nil, specMapItemMismatch{"value", previewWireValue(v.Value), err}
 		}
 
 		// Note: If the key is not hashable, we marshal it to a string and use that.
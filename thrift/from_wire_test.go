@@ -22,7 +22,9 @@ package thrift
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -395,11 +397,23 @@ func TestValueFromWireError(t *testing.T) {
 			}),
 			spec: &compile.ListSpec{ValueSpec: &compile.I16Spec{}},
 			err: specValueMismatch{"list<i16>",
-				specListItemMismatch{index: 0,
+				specListItemMismatch{index: 0, value: "0",
 					underlying: specTypeMismatch{specified: wire.TI16, got: wire.TI32},
 				},
 			},
 		},
+		{
+			msg: "list<string> -> list<i32>",
+			w: makeWireList(wire.TBinary, 2, func(i int) wire.Value {
+				return wire.NewValueString(fmt.Sprint("bad value ", i))
+			}),
+			spec: &compile.ListSpec{ValueSpec: &compile.I32Spec{}},
+			err: specValueMismatch{"list<i32>",
+				specListItemMismatch{index: 0, value: `"bad value 0"`,
+					underlying: specTypeMismatch{specified: wire.TI32, got: wire.TBinary},
+				},
+			},
+		},
 		{
 			msg: "map<i32,i32> -> map<i16,i32>",
 			w: makeWireMap(wire.TI32, wire.TI32, 3, func(i int) (key, value wire.Value) {
@@ -410,7 +424,7 @@ func TestValueFromWireError(t *testing.T) {
 				ValueSpec: &compile.I32Spec{},
 			},
 			err: specValueMismatch{"map<i16, i32>",
-				specMapItemMismatch{"key", specTypeMismatch{specified: wire.TI16, got: wire.TI32}},
+				specMapItemMismatch{"key", "0", specTypeMismatch{specified: wire.TI16, got: wire.TI32}},
 			},
 		},
 		{
@@ -423,7 +437,7 @@ func TestValueFromWireError(t *testing.T) {
 				ValueSpec: &compile.I32Spec{},
 			},
 			err: specValueMismatch{"map<i16, i32>",
-				specMapItemMismatch{"value", specTypeMismatch{specified: wire.TI32, got: wire.TI16}},
+				specMapItemMismatch{"value", "0", specTypeMismatch{specified: wire.TI32, got: wire.TI16}},
 			},
 		},
 		{
@@ -460,3 +474,71 @@ func TestValueFromWireError(t *testing.T) {
 		}
 	}
 }
+
+func TestValueFromWireListMismatchPreview(t *testing.T) {
+	longString := strings.Repeat("a", 100)
+	w := makeWireList(wire.TBinary, 1, func(i int) wire.Value {
+		return wire.NewValueString(longString)
+	})
+	spec := &compile.ListSpec{ValueSpec: &compile.I64Spec{}}
+
+	_, err := valueFromWire(spec, w)
+	require.Error(t, err, "Expected list item mismatch")
+	assert.Contains(t, err.Error(), "item 0 failed", "Error should include the index")
+	assert.Contains(t, err.Error(), `got value "aaaa`, "Error should include a preview of the value")
+	assert.Contains(t, err.Error(), "...", "Long values should be truncated")
+	assert.NotContains(t, err.Error(), longString, "Long values should not be included in full")
+}
+
+func TestPreviewWireValue(t *testing.T) {
+	tests := []struct {
+		w    wire.Value
+		want string
+	}{
+		{wire.NewValueBool(true), "true"},
+		{wire.NewValueI8(1), "1"},
+		{wire.NewValueI16(2), "2"},
+		{wire.NewValueI32(3), "3"},
+		{wire.NewValueI64(4), "4"},
+		{wire.NewValueDouble(1.5), "1.5"},
+		{wire.NewValueString("foo"), `"foo"`},
+		{wire.NewValueBinary([]byte{0, 1}), `"\x00\x01"`},
+		{makeWireList(wire.TI32, 3, func(i int) wire.Value { return wire.NewValueI32(int32(i)) }), "[0, 1, 2]"},
+		{
+			makeWireMap(wire.TBinary, wire.TI32, 1, func(i int) (wire.Value, wire.Value) {
+				return wire.NewValueString("k"), wire.NewValueI32(1)
+			}),
+			`{"k": 1}`,
+		},
+		{
+			wire.NewValueStruct(wire.Struct{Fields: []wire.Field{{ID: 1, Value: wire.NewValueBool(true)}}}),
+			"{1: true}",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, previewWireValue(tt.w), "Unexpected preview for %v", tt.w)
+	}
+}
+
+func TestPreviewWireValueTruncation(t *testing.T) {
+	// Each "é" is 2 bytes, and the opening quote shifts the cut point onto
+	// the middle of a rune.
+	multiByte := wire.NewValueString(strings.Repeat("é", 100))
+	got := previewWireValue(multiByte)
+	assert.True(t, utf8.ValidString(got), "Preview should be valid UTF-8: %q", got)
+	assert.True(t, strings.HasSuffix(got, "..."), "Preview should be truncated: %q", got)
+	assert.True(t, len(got) <= maxValuePreview+len("..."), "Preview too long: %q", got)
+
+	// Large binary values should only be quoted up to the preview limit.
+	got = previewWireValue(wire.NewValueBinary(make([]byte, 1<<20)))
+	assert.True(t, strings.HasPrefix(got, `"\x00\x00`), "Unexpected preview: %q", got)
+	assert.True(t, strings.HasSuffix(got, "..."), "Preview should be truncated: %q", got)
+	assert.True(t, len(got) <= maxValuePreview+len("..."), "Preview too long: %q", got)
+
+	// Large containers should only be rendered up to the preview limit.
+	large := makeWireList(wire.TI32, 10000, func(i int) wire.Value { return wire.NewValueI32(int32(i)) })
+	got = previewWireValue(large)
+	assert.True(t, strings.HasPrefix(got, "[0, 1, 2"), "Unexpected preview: %q", got)
+	assert.True(t, len(got) <= maxValuePreview+len("..."), "Preview too long: %q", got)
+}
@@ -20,8 +20,29 @@
 
 package thrift
 
+import "io"
+
 // Options controls the serialization of the Thrift request/response.
 type Options struct {
 	UseEnvelopes         bool
 	EnvelopeMethodPrefix string
+
+	// CheckEnvelopeMethod returns an error if the method name in the response
+	// envelope does not match the method that was called.
+	CheckEnvelopeMethod bool
+
+	// EnvelopeMethodWarnings, if set, receives a warning for a mismatched
+	// envelope method instead of the response failing. The warning is written
+	// by ResponseBytesToMap; CheckSuccess ignores the mismatch.
+	EnvelopeMethodWarnings io.Writer
+
+	// MaxStringBytes is the maximum length of any string or binary value in
+	// the response value. The default (0) is no limit. The envelope method
+	// name is not part of the value and is not checked. The limit is checked
+	// before the response is decoded, but after the transport has read the
+	// response body, so it does not limit the size of the body itself.
+	MaxStringBytes int
+
+	// TypeTagged wraps scalar values in the response with their Thrift type.
+	TypeTagged bool
 }
@@ -37,10 +37,18 @@ type encodedException struct {
 // ResponseBytesToMap takes the given response bytes and creates a map that
 // uses field name as keys.
 func ResponseBytesToMap(spec *compile.FunctionSpec, responseBytes []byte, opts Options) (map[string]interface{}, error) {
-	w, err := responseBytesToWire(responseBytes, opts)
+	w, envelopeMethod, err := responseBytesToWire(responseBytes, opts)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkEnvelopeMethod(spec, envelopeMethod, opts); err != nil {
+		// Mismatches are only reported here, where the response is decoded
+		// for the caller, so each response is warned about at most once.
+		if opts.EnvelopeMethodWarnings == nil {
+			return nil, err
+		}
+		fmt.Fprintf(opts.EnvelopeMethodWarnings, "Warning: %v\n", err)
+	}
 
 	var specs map[int16]*compile.FieldSpec
 	if spec.ResultSpec != nil {
@@ -69,9 +77,25 @@ func ResponseBytesToMap(spec *compile.FunctionSpec, responseBytes []byte, opts O
 		}
 	}
 
+	if opts.TypeTagged {
+		tagResultTypes(spec, specs, result)
+	}
+
 	return result, nil
 }
 
+// tagResultTypes replaces the values in result with type-tagged values.
+func tagResultTypes(spec *compile.FunctionSpec, exSpecs map[int16]*compile.FieldSpec, result map[string]interface{}) {
+	if v, ok := result["result"]; ok {
+		result["result"] = withTypeTags(spec.ResultSpec.ReturnType, v)
+	}
+	for _, exSpec := range exSpecs {
+		if v, ok := result[exSpec.Name]; ok {
+			result[exSpec.Name] = withTypeTags(exSpec.Type, v)
+		}
+	}
+}
+
 func checkException(spec *compile.FunctionSpec, fieldID int16) string {
 	if spec.ResultSpec == nil || len(spec.ResultSpec.Exceptions) == 0 {
 		return "unknown, method has no exceptions"
@@ -90,10 +114,13 @@ func checkException(spec *compile.FunctionSpec, fieldID int16) string {
 // - Thrift deserialization is successful (lazy fields are not evaluated)
 // - Only Field ID 0 (if the method has a return type) or no fields are set.
 func CheckSuccess(spec *compile.FunctionSpec, responseBytes []byte, opts Options) error {
-	w, err := responseBytesToWire(responseBytes, opts)
+	w, envelopeMethod, err := responseBytesToWire(responseBytes, opts)
 	if err != nil {
 		return wrapSerializeException(err, "could not deserialize result")
 	}
+	if err := checkEnvelopeMethod(spec, envelopeMethod, opts); err != nil && opts.EnvelopeMethodWarnings == nil {
+		return err
+	}
 
 	if spec.ResultSpec == nil || spec.ResultSpec.ReturnType == nil {
 		if len(w.Fields) == 0 {
@@ -116,20 +143,35 @@ func CheckSuccess(spec *compile.FunctionSpec, responseBytes []byte, opts Options
 	return nil
 }
 
-func responseBytesToWire(responseBytes []byte, opts Options) (wire.Struct, error) {
+// responseBytesToWire decodes the response struct, and returns it along with
+// the method name in the envelope, if envelopes are used.
+func responseBytesToWire(responseBytes []byte, opts Options) (_ wire.Struct, envelopeMethod string, _ error) {
 	var w wire.Value
 	var err error
 
+	if opts.MaxStringBytes > 0 {
+		if err := checkStringLengths(responseBytes, opts.UseEnvelopes, opts.MaxStringBytes); err != nil {
+			return wire.Struct{}, "", err
+		}
+	}
+
 	reader := bytes.NewReader(responseBytes)
 	if opts.UseEnvelopes {
-		w, _, err = envelope.ReadReply(protocol.Binary, bytes.NewReader(responseBytes))
+		e, err := protocol.Binary.DecodeEnveloped(reader)
 		if err != nil {
-			return wire.Struct{}, encodedException{err}
+			return wire.Struct{}, "", encodedException{err}
 		}
+		if e.Type != wire.Reply {
+			// ReadReply converts exceptions and unknown envelope types to errors.
+			if _, _, err := envelope.ReadReply(protocol.Binary, reader); err != nil {
+				return wire.Struct{}, "", encodedException{err}
+			}
+		}
+		w, envelopeMethod = e.Value, e.Name
 	} else {
 		w, err = protocol.Binary.Decode(reader, wire.TStruct)
 		if err != nil {
-			return wire.Struct{}, fmt.Errorf("cannot parse Thrift struct from response: %v", err)
+			return wire.Struct{}, "", fmt.Errorf("cannot parse Thrift struct from response: %v", err)
 		}
 	}
 
@@ -137,7 +179,23 @@ func responseBytesToWire(responseBytes []byte, opts Options) (wire.Struct, error
 		panic("Got unexpected type when parsing struct")
 	}
 
-	return w.GetStruct(), nil
+	return w.GetStruct(), envelopeMethod, nil
+}
+
+// checkEnvelopeMethod returns an error if CheckEnvelopeMethod is enabled and
+// the response envelope is for a different method than spec.
+func checkEnvelopeMethod(spec *compile.FunctionSpec, envelopeMethod string, opts Options) error {
+	if !opts.UseEnvelopes || !opts.CheckEnvelopeMethod {
+		return nil
+	}
+
+	// Multiplexed servers may reply with or without the service prefix.
+	if envelopeMethod == spec.Name || envelopeMethod == opts.EnvelopeMethodPrefix+spec.Name {
+		return nil
+	}
+
+	return fmt.Errorf("response envelope method %q does not match requested method %q",
+		envelopeMethod, opts.EnvelopeMethodPrefix+spec.Name)
 }
 
 func wrapSerializeException(err error, msg string) error {
@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/thriftrw/compile"
 	"go.uber.org/thriftrw/protocol"
 	"go.uber.org/thriftrw/wire"
@@ -90,6 +91,39 @@ func TestResponseBytesToMap(t *testing.T) {
 			opts: Options{UseEnvelopes: true},
 			want: map[string]interface{}{},
 		},
+		{
+			msg:  "fVoid with matching envelope method",
+			spec: funcSpecs["fVoid"],
+			bs: encodeEnveloped(wire.Envelope{
+				Name:  "fVoid",
+				Type:  wire.Reply,
+				Value: wire.NewValueStruct(wire.Struct{}),
+			}),
+			opts: Options{UseEnvelopes: true, CheckEnvelopeMethod: true},
+			want: map[string]interface{}{},
+		},
+		{
+			msg:  "fVoid with mismatched envelope method",
+			spec: funcSpecs["fVoid"],
+			bs: encodeEnveloped(wire.Envelope{
+				Name:  "fStr",
+				Type:  wire.Reply,
+				Value: wire.NewValueStruct(wire.Struct{}),
+			}),
+			opts:   Options{UseEnvelopes: true, CheckEnvelopeMethod: true},
+			errMsg: `response envelope method "fStr" does not match requested method "fVoid"`,
+		},
+		{
+			msg:  "fVoid with mismatched envelope method without check",
+			spec: funcSpecs["fVoid"],
+			bs: encodeEnveloped(wire.Envelope{
+				Name:  "fStr",
+				Type:  wire.Reply,
+				Value: wire.NewValueStruct(wire.Struct{}),
+			}),
+			opts: Options{UseEnvelopes: true},
+			want: map[string]interface{}{},
+		},
 		{
 			msg:  "fStr with str result",
 			spec: funcSpecs["fStr"],
@@ -114,6 +148,48 @@ func TestResponseBytesToMap(t *testing.T) {
 				},
 			},
 		},
+		{
+			msg:  "fStr with str result and type tags",
+			spec: funcSpecs["fStr"],
+			bs:   encodeWire(wire.NewValueStruct(s)),
+			opts: Options{TypeTagged: true},
+			want: map[string]interface{}{
+				"result": taggedValue{Type: "string", Value: "foo"},
+			},
+		},
+		{
+			msg:  "fEx with exception and type tags",
+			spec: funcSpecs["fEx"],
+			bs: encodeWire(wire.NewValueStruct(wire.Struct{
+				Fields: []wire.Field{
+					{ID: 1, Value: wire.NewValueStruct(wire.Struct{
+						Fields: []wire.Field{{ID: 1, Value: wire.NewValueString("bar")}},
+					})},
+				},
+			})),
+			opts: Options{TypeTagged: true},
+			want: map[string]interface{}{
+				"e": map[string]interface{}{
+					"reason": taggedValue{Type: "string", Value: "bar"},
+				},
+			},
+		},
+		{
+			msg:    "fStr with str result over the string limit",
+			spec:   funcSpecs["fStr"],
+			bs:     encodeWire(wire.NewValueStruct(s)),
+			opts:   Options{MaxStringBytes: 2},
+			errMsg: "has length 3 which exceeds the limit of 2 bytes",
+		},
+		{
+			msg:  "fStr with str result at the string limit",
+			spec: funcSpecs["fStr"],
+			bs:   encodeWire(wire.NewValueStruct(s)),
+			opts: Options{MaxStringBytes: 3},
+			want: map[string]interface{}{
+				"result": "foo",
+			},
+		},
 		{
 			msg:    "fVoid with str result",
 			spec:   funcSpecs["fVoid"],
@@ -191,6 +267,16 @@ func TestResponseBytesToWire(t *testing.T) {
 			opts: Options{UseEnvelopes: true},
 			want: s,
 		},
+		{
+			msg: "exception envelope",
+			bs: encodeEnveloped(wire.Envelope{
+				Name:  "method",
+				Type:  wire.Exception,
+				Value: wire.NewValueStruct(wire.Struct{}),
+			}),
+			opts:   Options{UseEnvelopes: true},
+			errMsg: "TApplicationException",
+		},
 		{
 			msg:    "bool instead of struct",
 			bs:     encodeWire(wire.NewValueBool(true)),
@@ -204,7 +290,7 @@ func TestResponseBytesToWire(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		got, err := responseBytesToWire(tt.bs, tt.opts)
+		got, _, err := responseBytesToWire(tt.bs, tt.opts)
 		if tt.errMsg != "" {
 			if assert.Error(t, err, "Expected to fail: %s", tt.msg) {
 				assert.Contains(t, err.Error(), tt.errMsg, "Error message mismatch: %s", tt.msg)
@@ -218,6 +304,46 @@ func TestResponseBytesToWire(t *testing.T) {
 	}
 }
 
+func TestResponseBytesToWireEnvelopeMethod(t *testing.T) {
+	bs := encodeEnveloped(wire.Envelope{
+		Name:  "method",
+		Type:  wire.Reply,
+		Value: wire.NewValueStruct(wire.Struct{}),
+	})
+
+	_, envelopeMethod, err := responseBytesToWire(bs, Options{UseEnvelopes: true})
+	require.NoError(t, err, "Failed to decode enveloped response")
+	assert.Equal(t, "method", envelopeMethod, "Unexpected envelope method")
+
+	_, envelopeMethod, err = responseBytesToWire(encodeWire(wire.NewValueStruct(wire.Struct{})), Options{})
+	require.NoError(t, err, "Failed to decode response")
+	assert.Empty(t, envelopeMethod, "Unenveloped responses have no method")
+}
+
+func TestEnvelopeMethodWarnings(t *testing.T) {
+	funcSpecs := getFuncSpecs(t, `
+    service Test {
+      void m1()
+    }
+  `)
+
+	bs := encodeEnveloped(wire.Envelope{
+		Name:  "other",
+		Type:  wire.Reply,
+		Value: wire.NewValueStruct(wire.Struct{}),
+	})
+
+	var warnings bytes.Buffer
+	opts := Options{UseEnvelopes: true, CheckEnvelopeMethod: true, EnvelopeMethodWarnings: &warnings}
+
+	_, err := ResponseBytesToMap(funcSpecs["m1"], bs, opts)
+	assert.NoError(t, err, "Mismatched method should only warn")
+	assert.NoError(t, CheckSuccess(funcSpecs["m1"], bs, opts), "Mismatched method should only warn")
+	assert.Equal(t,
+		"Warning: response envelope method \"other\" does not match requested method \"m1\"\n",
+		warnings.String(), "Response should only be warned about once")
+}
+
 func TestCheckSuccess(t *testing.T) {
 	funcSpecs := getFuncSpecs(t, `
     exception E {
@@ -274,6 +400,37 @@ func TestCheckSuccess(t *testing.T) {
 			}),
 			opts: Options{UseEnvelopes: true},
 		},
+		{
+			msg:    "void success with matching envelope method",
+			method: "m1",
+			bs: encodeEnveloped(wire.Envelope{
+				Name:  "m1",
+				Type:  wire.Reply,
+				Value: emptyResult,
+			}),
+			opts: Options{UseEnvelopes: true, CheckEnvelopeMethod: true},
+		},
+		{
+			msg:    "void success with matching multiplexed envelope method",
+			method: "m1",
+			bs: encodeEnveloped(wire.Envelope{
+				Name:  "Test:m1",
+				Type:  wire.Reply,
+				Value: emptyResult,
+			}),
+			opts: Options{UseEnvelopes: true, CheckEnvelopeMethod: true, EnvelopeMethodPrefix: "Test:"},
+		},
+		{
+			msg:    "void success with mismatched envelope method",
+			method: "m1",
+			bs: encodeEnveloped(wire.Envelope{
+				Name:  "method",
+				Type:  wire.Reply,
+				Value: emptyResult,
+			}),
+			opts:   Options{UseEnvelopes: true, CheckEnvelopeMethod: true},
+			errMsg: `response envelope method "method" does not match requested method "m1"`,
+		},
 		{
 			msg:    "exception in envelope",
 			method: "m1",
@@ -0,0 +1,289 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/thriftrw/wire"
+)
+
+// errMalformed is returned internally when the payload cannot be scanned.
+// Malformed payloads are reported by the decoder instead.
+var errMalformed = errors.New("malformed payload")
+
+// stringLengthError is returned when a string or binary value is larger
+// than the configured limit.
+type stringLengthError struct {
+	length int32
+	limit  int
+	offset int
+	path   string
+}
+
+func (e stringLengthError) Error() string {
+	return fmt.Sprintf("string/binary value in field %v at offset %v has length %v which exceeds the limit of %v bytes",
+		e.path, e.offset, e.length, e.limit)
+}
+
+// checkStringLengths scans a Thrift binary payload and returns an error if
+// any string or binary value is longer than limit. It only reads the length
+// prefixes, so oversize values are rejected before the payload is decoded.
+func checkStringLengths(bs []byte, useEnvelopes bool, limit int) error {
+	s := &lengthScanner{bs: bs, limit: limit}
+	err := s.scanPayload(useEnvelopes)
+	if err == errMalformed {
+		return nil
+	}
+	return err
+}
+
+type lengthScanner struct {
+	bs    []byte
+	pos   int
+	limit int
+
+	// path is the field IDs and element indexes leading to the current
+	// value, used to report which value exceeded the limit.
+	path []string
+}
+
+func (s *lengthScanner) scanPayload(useEnvelopes bool) error {
+	if useEnvelopes {
+		if err := s.scanEnvelopeHeader(); err != nil {
+			return err
+		}
+	}
+	return s.scanValue(wire.TStruct)
+}
+
+func (s *lengthScanner) scanEnvelopeHeader() error {
+	version, err := s.readInt32()
+	if err != nil {
+		return err
+	}
+
+	// The method name is not part of the response value, so its length is
+	// not checked against the limit.
+	if version < 0 {
+		// Strict envelope: version | type, name, seqID.
+		nameLength, err := s.readSize()
+		if err != nil {
+			return err
+		}
+		return s.skip(nameLength + 4)
+	}
+
+	// Non-strict envelope: the version is the length of the name,
+	// followed by the name, type and seqID.
+	return s.skip(int(version) + 1 + 4)
+}
+
+func (s *lengthScanner) scanValue(t wire.Type) error {
+	switch t {
+	case wire.TBool, wire.TI8:
+		return s.skip(1)
+	case wire.TI16:
+		return s.skip(2)
+	case wire.TI32:
+		return s.skip(4)
+	case wire.TI64, wire.TDouble:
+		return s.skip(8)
+	case wire.TBinary:
+		return s.scanBinary()
+	case wire.TStruct:
+		return s.scanStruct()
+	case wire.TMap:
+		return s.scanMap()
+	case wire.TSet, wire.TList:
+		return s.scanList()
+	default:
+		return errMalformed
+	}
+}
+
+func (s *lengthScanner) scanBinary() error {
+	offset := s.pos
+	length, err := s.readInt32()
+	if err != nil {
+		return err
+	}
+	if err := s.checkLength(length, offset); err != nil {
+		return err
+	}
+	return s.skip(int(length))
+}
+
+func (s *lengthScanner) scanStruct() error {
+	for {
+		t, err := s.readType()
+		if err != nil {
+			return err
+		}
+		if t == 0 {
+			// Field stop.
+			return nil
+		}
+
+		id, err := s.readInt16()
+		if err != nil {
+			return err
+		}
+
+		s.push(strconv.Itoa(int(id)))
+		if err := s.scanValue(t); err != nil {
+			return err
+		}
+		s.pop()
+	}
+}
+
+func (s *lengthScanner) scanMap() error {
+	kt, err := s.readType()
+	if err != nil {
+		return err
+	}
+	vt, err := s.readType()
+	if err != nil {
+		return err
+	}
+	size, err := s.readSize()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < size; i++ {
+		s.push(fmt.Sprintf("[%v].key", i))
+		if err := s.scanValue(kt); err != nil {
+			return err
+		}
+		s.pop()
+
+		s.push(fmt.Sprintf("[%v].value", i))
+		if err := s.scanValue(vt); err != nil {
+			return err
+		}
+		s.pop()
+	}
+	return nil
+}
+
+func (s *lengthScanner) scanList() error {
+	t, err := s.readType()
+	if err != nil {
+		return err
+	}
+	size, err := s.readSize()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < size; i++ {
+		s.push(fmt.Sprintf("[%v]", i))
+		if err := s.scanValue(t); err != nil {
+			return err
+		}
+		s.pop()
+	}
+	return nil
+}
+
+func (s *lengthScanner) checkLength(length int32, offset int) error {
+	if length < 0 {
+		return errMalformed
+	}
+	if int64(length) > int64(s.limit) {
+		return stringLengthError{length: length, limit: s.limit, offset: offset, path: s.pathString()}
+	}
+	return nil
+}
+
+func (s *lengthScanner) push(segment string) {
+	s.path = append(s.path, segment)
+}
+
+func (s *lengthScanner) pop() {
+	s.path = s.path[:len(s.path)-1]
+}
+
+// pathString returns the current path, with field IDs separated by "." and
+// element indexes in brackets, e.g. 2[0].value.1.
+func (s *lengthScanner) pathString() string {
+	var buf bytes.Buffer
+	for i, segment := range s.path {
+		if i > 0 && !strings.HasPrefix(segment, "[") {
+			buf.WriteByte('.')
+		}
+		buf.WriteString(segment)
+	}
+	return buf.String()
+}
+
+func (s *lengthScanner) readType() (wire.Type, error) {
+	if s.pos >= len(s.bs) {
+		return 0, errMalformed
+	}
+	t := wire.Type(s.bs[s.pos])
+	s.pos++
+	return t, nil
+}
+
+func (s *lengthScanner) readInt16() (int16, error) {
+	if s.pos+2 > len(s.bs) {
+		return 0, errMalformed
+	}
+	v := int16(binary.BigEndian.Uint16(s.bs[s.pos:]))
+	s.pos += 2
+	return v, nil
+}
+
+func (s *lengthScanner) readInt32() (int32, error) {
+	if s.pos+4 > len(s.bs) {
+		return 0, errMalformed
+	}
+	v := int32(binary.BigEndian.Uint32(s.bs[s.pos:]))
+	s.pos += 4
+	return v, nil
+}
+
+func (s *lengthScanner) readSize() (int, error) {
+	size, err := s.readInt32()
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 {
+		return 0, errMalformed
+	}
+	return int(size), nil
+}
+
+func (s *lengthScanner) skip(n int) error {
+	if n < 0 || s.pos+n > len(s.bs) {
+		return errMalformed
+	}
+	s.pos += n
+	return nil
+}
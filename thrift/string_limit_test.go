@@ -0,0 +1,186 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/thriftrw/wire"
+)
+
+func TestCheckStringLengths(t *testing.T) {
+	long := wire.NewValueString(strings.Repeat("a", 100))
+	short := wire.NewValueString("short")
+
+	structWith := func(v wire.Value) wire.Value {
+		return wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+			{ID: 1, Value: wire.NewValueI32(1)},
+			{ID: 2, Value: v},
+		}})
+	}
+
+	tests := []struct {
+		msg          string
+		bs           []byte
+		useEnvelopes bool
+		wantErr      bool
+		wantPath     string
+	}{
+		{
+			msg: "short string field",
+			bs:  encodeWire(structWith(short)),
+		},
+		{
+			msg:      "long string field",
+			bs:       encodeWire(structWith(long)),
+			wantErr:  true,
+			wantPath: "2",
+		},
+		{
+			msg:      "long binary field",
+			bs:       encodeWire(structWith(wire.NewValueBinary(make([]byte, 51)))),
+			wantErr:  true,
+			wantPath: "2",
+		},
+		{
+			msg: "binary field at the limit",
+			bs:  encodeWire(structWith(wire.NewValueBinary(make([]byte, 50)))),
+		},
+		{
+			msg:      "long string in nested struct",
+			bs:       encodeWire(structWith(structWith(long))),
+			wantErr:  true,
+			wantPath: "2.2",
+		},
+		{
+			msg: "long string in list",
+			bs: encodeWire(structWith(makeWireList(wire.TBinary, 3, func(i int) wire.Value {
+				if i == 2 {
+					return long
+				}
+				return short
+			}))),
+			wantErr:  true,
+			wantPath: "2[2]",
+		},
+		{
+			msg: "long string as map value",
+			bs: encodeWire(structWith(makeWireMap(wire.TI32, wire.TBinary, 1, func(i int) (wire.Value, wire.Value) {
+				return wire.NewValueI32(1), long
+			}))),
+			wantErr:  true,
+			wantPath: "2[0].value",
+		},
+		{
+			msg: "short strings in map",
+			bs: encodeWire(structWith(makeWireMap(wire.TBinary, wire.TBinary, 2, func(i int) (wire.Value, wire.Value) {
+				return wire.NewValueString(string('a' + rune(i))), short
+			}))),
+		},
+		{
+			msg: "short string in envelope",
+			bs: encodeEnveloped(wire.Envelope{
+				Name:  "method",
+				Type:  wire.Reply,
+				Value: structWith(short),
+			}),
+			useEnvelopes: true,
+		},
+		{
+			msg: "long string in envelope",
+			bs: encodeEnveloped(wire.Envelope{
+				Name:  "method",
+				Type:  wire.Reply,
+				Value: structWith(long),
+			}),
+			useEnvelopes: true,
+			wantErr:      true,
+			wantPath:     "2",
+		},
+		{
+			msg: "long envelope name is not checked",
+			bs: encodeEnveloped(wire.Envelope{
+				Name:  strings.Repeat("m", 100),
+				Type:  wire.Reply,
+				Value: structWith(short),
+			}),
+			useEnvelopes: true,
+		},
+		{
+			msg: "long non-strict envelope name is not checked",
+			bs: append(append([]byte{
+				0x00, 0x00, 0x00, 0x64, // name length
+			}, strings.Repeat("m", 100)...),
+				0x02,                   // type = reply
+				0x00, 0x00, 0x00, 0x01, // seqID
+				0x00, // field stop
+			),
+			useEnvelopes: true,
+		},
+		{
+			msg: "long string in map value struct",
+			bs: encodeWire(structWith(makeWireMap(wire.TBinary, wire.TStruct, 2, func(i int) (wire.Value, wire.Value) {
+				if i == 1 {
+					return wire.NewValueString("b"), structWith(long)
+				}
+				return wire.NewValueString("a"), structWith(short)
+			}))),
+			wantErr:  true,
+			wantPath: "2[1].value.2",
+		},
+		{
+			msg: "long string as map key",
+			bs: encodeWire(structWith(makeWireMap(wire.TBinary, wire.TI32, 1, func(i int) (wire.Value, wire.Value) {
+				return long, wire.NewValueI32(1)
+			}))),
+			wantErr:  true,
+			wantPath: "2[0].key",
+		},
+		{
+			msg: "truncated payload is left to the decoder",
+			bs:  encodeWire(structWith(short))[:5],
+		},
+		{
+			msg: "length prefix larger than the payload",
+			bs: []byte{
+				0x0B, 0x00, 0x01, // type = binary | field ID = 1
+				0x7F, 0xFF, 0xFF, 0xFF, // length
+			},
+			wantErr:  true,
+			wantPath: "1",
+		},
+	}
+
+	for _, tt := range tests {
+		err := checkStringLengths(tt.bs, tt.useEnvelopes, 50)
+		if !tt.wantErr {
+			assert.NoError(t, err, "Unexpected error for %v", tt.msg)
+			continue
+		}
+
+		if assert.Error(t, err, "Expected error for %v", tt.msg) {
+			assert.Contains(t, err.Error(), "which exceeds the limit of 50 bytes", "Unexpected error for %v", tt.msg)
+			assert.Contains(t, err.Error(), "in field "+tt.wantPath+" at offset", "Unexpected field path for %v", tt.msg)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/yarpc/yab/sorted"
+
+	"go.uber.org/thriftrw/compile"
+	"go.uber.org/thriftrw/wire"
+)
+
+// taggedValue is a scalar value annotated with its Thrift type, so that
+// the type is not lost when the value is serialized to JSON.
+type taggedValue struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// taggedMapItem is a single item of a map whose key and value are tagged.
+type taggedMapItem struct {
+	Key   interface{} `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// withTypeTags replaces every scalar within v with a taggedValue using the
+// given spec. v must be a value returned by valueFromWire for the same spec.
+func withTypeTags(spec compile.TypeSpec, v interface{}) interface{} {
+	spec = compile.RootTypeSpec(spec)
+	switch spec := spec.(type) {
+	case *compile.StructSpec:
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+
+		result := make(map[string]interface{}, len(fields))
+		for _, f := range spec.Fields {
+			if fv, ok := fields[f.Name]; ok {
+				result[f.Name] = withTypeTags(f.Type, fv)
+			}
+		}
+		return result
+	case *compile.ListSpec:
+		return listWithTypeTags(spec.ValueSpec, v)
+	case *compile.SetSpec:
+		return listWithTypeTags(spec.ValueSpec, v)
+	case *compile.MapSpec:
+		items, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+
+		// JSON object keys are always strings, so maps are converted to a
+		// list of items, which allows keys to be tagged as well.
+		result := make([]interface{}, 0, len(items))
+		for _, k := range sorted.MapKeys(items) {
+			result = append(result, taggedMapItem{
+				Key:   mapKeyWithTypeTags(spec.KeySpec, k),
+				Value: withTypeTags(spec.ValueSpec, items[k]),
+			})
+		}
+		return result
+	}
+
+	return taggedValue{Type: scalarTypeName(spec), Value: v}
+}
+
+// mapKeyWithTypeTags tags a map key. valueFromWireMap uses keys that are
+// strings as-is, and marshals other keys to JSON, so scalar keys are recovered
+// from their string form. Keys of other types are left as strings.
+func mapKeyWithTypeTags(spec compile.TypeSpec, k string) interface{} {
+	spec = compile.RootTypeSpec(spec)
+
+	var (
+		key interface{}
+		err error
+	)
+	switch spec.(type) {
+	case *compile.StringSpec, *compile.EnumSpec:
+		key = k
+	case *compile.BoolSpec:
+		key, err = strconv.ParseBool(k)
+	case *compile.I8Spec:
+		var n int64
+		n, err = strconv.ParseInt(k, 10, 8)
+		key = int8(n)
+	case *compile.I16Spec:
+		var n int64
+		n, err = strconv.ParseInt(k, 10, 16)
+		key = int16(n)
+	case *compile.I32Spec:
+		var n int64
+		n, err = strconv.ParseInt(k, 10, 32)
+		key = int32(n)
+	case *compile.I64Spec:
+		key, err = strconv.ParseInt(k, 10, 64)
+	case *compile.DoubleSpec:
+		key, err = strconv.ParseFloat(k, 64)
+	case *compile.BinarySpec:
+		var bs []byte
+		err = json.Unmarshal([]byte(k), &bs)
+		key = bs
+	default:
+		return k
+	}
+
+	if err != nil {
+		return k
+	}
+	return taggedValue{Type: scalarTypeName(spec), Value: key}
+}
+
+func listWithTypeTags(spec compile.TypeSpec, v interface{}) interface{} {
+	items, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+
+	result := make([]interface{}, len(items))
+	for i, iv := range items {
+		result[i] = withTypeTags(spec, iv)
+	}
+	return result
+}
+
+// scalarTypeName returns the Thrift name of the type of the given scalar
+// spec. Enums are decoded to their item name, so they are reported as enum
+// rather than as the i32 used on the wire.
+func scalarTypeName(spec compile.TypeSpec) string {
+	if _, ok := spec.(*compile.EnumSpec); ok {
+		return "enum"
+	}
+
+	switch spec.TypeCode() {
+	case wire.TBool:
+		return "bool"
+	case wire.TI8:
+		return "i8"
+	case wire.TI16:
+		return "i16"
+	case wire.TI32:
+		return "i32"
+	case wire.TI64:
+		return "i64"
+	case wire.TDouble:
+		return "double"
+	case wire.TBinary:
+		if _, ok := spec.(*compile.StringSpec); ok {
+			return "string"
+		}
+		return "binary"
+	}
+
+	return spec.ThriftName()
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package thrift
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/thriftrw/ast"
+	"go.uber.org/thriftrw/compile"
+)
+
+func TestWithTypeTagsScalars(t *testing.T) {
+	tests := []struct {
+		spec     compile.TypeSpec
+		v        interface{}
+		wantType string
+	}{
+		{&compile.BoolSpec{}, true, "bool"},
+		{&compile.I8Spec{}, int8(1), "i8"},
+		{&compile.I16Spec{}, int16(2), "i16"},
+		{&compile.I32Spec{}, int32(3), "i32"},
+		{&compile.I64Spec{}, int64(4), "i64"},
+		{&compile.DoubleSpec{}, 1.5, "double"},
+		{&compile.StringSpec{}, "foo", "string"},
+		{&compile.BinarySpec{}, []byte("bar"), "binary"},
+		{&compile.EnumSpec{Name: "E"}, "A", "enum"},
+		{&compile.EnumSpec{Name: "E"}, "E(7)", "enum"},
+		{&compile.TypedefSpec{Name: "UUID", Target: &compile.I64Spec{}}, int64(5), "i64"},
+	}
+
+	for _, tt := range tests {
+		got := withTypeTags(tt.spec, tt.v)
+		assert.Equal(t, taggedValue{Type: tt.wantType, Value: tt.v}, got,
+			"Unexpected tagged value for %v", tt.spec.ThriftName())
+	}
+}
+
+func TestWithTypeTagsContainers(t *testing.T) {
+	spec := &compile.StructSpec{
+		Name: "S",
+		Type: ast.StructType,
+		Fields: compile.FieldGroup{
+			{ID: 1, Name: "ids", Type: &compile.ListSpec{ValueSpec: &compile.I64Spec{}}},
+			{ID: 2, Name: "tags", Type: &compile.SetSpec{ValueSpec: &compile.StringSpec{}}},
+			{ID: 3, Name: "counts", Type: &compile.MapSpec{
+				KeySpec:   &compile.StringSpec{},
+				ValueSpec: &compile.I32Spec{},
+			}},
+			{ID: 4, Name: "nested", Type: &compile.ListSpec{
+				ValueSpec: &compile.ListSpec{ValueSpec: &compile.I16Spec{}},
+			}},
+			{ID: 5, Name: "unset", Type: &compile.BoolSpec{}},
+		},
+	}
+
+	v := map[string]interface{}{
+		"ids":    []interface{}{int64(1), int64(2)},
+		"tags":   []interface{}{"a"},
+		"counts": map[string]interface{}{"x": int32(3)},
+		"nested": []interface{}{[]interface{}{int16(4)}},
+	}
+
+	want := map[string]interface{}{
+		"ids": []interface{}{
+			taggedValue{"i64", int64(1)},
+			taggedValue{"i64", int64(2)},
+		},
+		"tags": []interface{}{taggedValue{"string", "a"}},
+		"counts": []interface{}{
+			taggedMapItem{taggedValue{"string", "x"}, taggedValue{"i32", int32(3)}},
+		},
+		"nested": []interface{}{[]interface{}{taggedValue{"i16", int16(4)}}},
+	}
+	assert.Equal(t, want, withTypeTags(spec, v), "Unexpected tagged struct")
+}
+
+func TestWithTypeTagsMapKeys(t *testing.T) {
+	tests := []struct {
+		keySpec compile.TypeSpec
+		keys    map[string]interface{}
+		want    []interface{}
+	}{
+		{
+			keySpec: &compile.I32Spec{},
+			keys:    map[string]interface{}{"2": true, "1": false},
+			want: []interface{}{
+				taggedMapItem{taggedValue{"i32", int32(1)}, taggedValue{"bool", false}},
+				taggedMapItem{taggedValue{"i32", int32(2)}, taggedValue{"bool", true}},
+			},
+		},
+		{
+			keySpec: &compile.I64Spec{},
+			keys:    map[string]interface{}{"1": true},
+			want:    []interface{}{taggedMapItem{taggedValue{"i64", int64(1)}, taggedValue{"bool", true}}},
+		},
+		{
+			keySpec: &compile.I8Spec{},
+			keys:    map[string]interface{}{"-3": true},
+			want:    []interface{}{taggedMapItem{taggedValue{"i8", int8(-3)}, taggedValue{"bool", true}}},
+		},
+		{
+			keySpec: &compile.I16Spec{},
+			keys:    map[string]interface{}{"300": true},
+			want:    []interface{}{taggedMapItem{taggedValue{"i16", int16(300)}, taggedValue{"bool", true}}},
+		},
+		{
+			keySpec: &compile.BoolSpec{},
+			keys:    map[string]interface{}{"true": true},
+			want:    []interface{}{taggedMapItem{taggedValue{"bool", true}, taggedValue{"bool", true}}},
+		},
+		{
+			keySpec: &compile.DoubleSpec{},
+			keys:    map[string]interface{}{"1.5": true},
+			want:    []interface{}{taggedMapItem{taggedValue{"double", 1.5}, taggedValue{"bool", true}}},
+		},
+		{
+			keySpec: &compile.BinarySpec{},
+			keys:    map[string]interface{}{`"AQI="`: true},
+			want:    []interface{}{taggedMapItem{taggedValue{"binary", []byte{1, 2}}, taggedValue{"bool", true}}},
+		},
+		{
+			keySpec: &compile.EnumSpec{Name: "E"},
+			keys:    map[string]interface{}{"A": true},
+			want:    []interface{}{taggedMapItem{taggedValue{"enum", "A"}, taggedValue{"bool", true}}},
+		},
+		{
+			// Keys that are not scalars are left as their JSON string.
+			keySpec: &compile.ListSpec{ValueSpec: &compile.I32Spec{}},
+			keys:    map[string]interface{}{"[1]": true},
+			want:    []interface{}{taggedMapItem{"[1]", taggedValue{"bool", true}}},
+		},
+	}
+
+	for _, tt := range tests {
+		spec := &compile.MapSpec{KeySpec: tt.keySpec, ValueSpec: &compile.BoolSpec{}}
+		assert.Equal(t, tt.want, withTypeTags(spec, tt.keys), "Unexpected tagged map for %v", spec.ThriftName())
+	}
+}
+
+func TestTypeTaggedJSON(t *testing.T) {
+	spec := &compile.ListSpec{ValueSpec: &compile.I64Spec{}}
+	bs, err := json.Marshal(withTypeTags(spec, []interface{}{int64(1)}))
+	require.NoError(t, err, "Failed to marshal tagged value")
+	assert.Equal(t, `[{"type":"i64","value":1}]`, string(bs), "Unexpected JSON output")
+
+	// Maps with i32 and i64 keys can be told apart.
+	mapSpec := &compile.MapSpec{KeySpec: &compile.I64Spec{}, ValueSpec: &compile.StringSpec{}}
+	bs, err = json.Marshal(withTypeTags(mapSpec, map[string]interface{}{"1": "a"}))
+	require.NoError(t, err, "Failed to marshal tagged map")
+	assert.Equal(t, `[{"key":{"type":"i64","value":1},"value":{"type":"string","value":"a"}}]`,
+		string(bs), "Unexpected JSON output for map")
+}